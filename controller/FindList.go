@@ -391,8 +391,29 @@ func GetGroupHandler(ctx *gin.Context) {
 		return
 	}
 
+	type GroupResp struct {
+		model.Group
+		ConvID string `json:"conv_id"`
+		Unread int64  `json:"unread"`
+	}
+
+	// 附加每个群聊的未读消息数
+	groupsResponse := make([]GroupResp, len(groups))
+	convIDs := make([]string, len(groups))
+	for i, group := range groups {
+		convIDs[i] = conversationID(model.GROUP_CHAT, int(userIDInt), group.GroupID)
+		groupsResponse[i] = GroupResp{Group: group, ConvID: convIDs[i]}
+	}
+	unread, err := getUnreadCounts(ctx, int(userIDInt), convIDs)
+	if err != nil {
+		log.Printf("获取群聊未读数失败: %v", err)
+	}
+	for i := range groupsResponse {
+		groupsResponse[i].Unread = unread[groupsResponse[i].ConvID]
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
-		"groups": groups,
+		"groups": groupsResponse,
 	})
 }
 
@@ -423,6 +444,8 @@ func GetFriendsHandler(ctx *gin.Context) {
 		ID        uint   `json:"id"`
 		Username  string `json:"username"`
 		AvatarURL string `json:"avatar_url"`
+		ConvID    string `json:"conv_id"`
+		Unread    int64  `json:"unread"`
 	}
 
 	jsonData, err := json.Marshal(friends)
@@ -440,6 +463,20 @@ func GetFriendsHandler(ctx *gin.Context) {
 	//	})
 	//}
 
+	// 附加每个单聊的未读消息数
+	convIDs := make([]string, len(friendsResponse))
+	for i := range friendsResponse {
+		convIDs[i] = conversationID(model.SINGLE_CHAT, int(userIDInt), int(friendsResponse[i].ID))
+		friendsResponse[i].ConvID = convIDs[i]
+	}
+	unread, err := getUnreadCounts(ctx, int(userIDInt), convIDs)
+	if err != nil {
+		log.Printf("获取好友未读数失败: %v", err)
+	}
+	for i := range friendsResponse {
+		friendsResponse[i].Unread = unread[friendsResponse[i].ConvID]
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"friends": friendsResponse,
 	})
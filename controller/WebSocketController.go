@@ -1,18 +1,205 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
 	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/helpleness/IMChatAdmin/service/presence"
 	"github.com/helpleness/IMChatAdmin/service/websocket"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
 )
 
+const (
+	wsCommandTimeout = 10 * time.Second // 单个 WebSocket 命令的处理超时时间
+	typingInterval   = 3 * time.Second  // 同一用户在同一会话中发送输入状态的最小间隔
+)
+
+// typingLimitKey 输入状态限流键
+func typingLimitKey(userID uint, convID string) string {
+	return fmt.Sprintf("typing_limit:%d:%s", userID, convID)
+}
+
+// LoginController 连接在握手时已经完成认证，这里返回当前连接绑定的用户
 func LoginController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
-	code = 200
-	//currentTime := uint32(time.Now().Unix())
-	request := &model.User{}
-	if err := json.Unmarshal(message, request); err != nil {
+	return http.StatusOK, "登录成功", gin.H{"user_id": client.UserID, "app_id": client.AppID}
+}
+
+// PingController 心跳探测
+func PingController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	return http.StatusOK, "pong", nil
+}
+
+// HeartbeatController 心跳，刷新连接的心跳时间并为在线状态续期
+// 客户端切到后台或长时间无操作时可以在心跳中上报 away
+func HeartbeatController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	var req request.WsHeartbeat
+	if len(message) > 0 {
+		if err := json.Unmarshal(message, &req); err != nil {
+			return http.StatusBadRequest, err.Error(), nil
+		}
+	}
+	if req.Status == "" {
+		req.Status = presence.StatusOnline
+	}
+	if !presence.ValidStatus(req.Status) {
+		return http.StatusBadRequest, "无效的状态", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsCommandTimeout)
+	defer cancel()
+
+	if err := websocket.RefreshHeartbeat(ctx, client, req.Status); err != nil {
+		return http.StatusInternalServerError, err.Error(), nil
+	}
+	return http.StatusOK, "", gin.H{"status": req.Status}
+}
+
+// SendMessageController 通过 WebSocket 发送单聊或群聊消息，处理逻辑与 HTTP 接口相同
+func SendMessageController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	var req request.WsMessageSend
+	if err := json.Unmarshal(message, &req); err != nil {
+		return http.StatusBadRequest, err.Error(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsCommandTimeout)
+	defer cancel()
+
+	result, err := postMessage(ctx, client.UserID, req.ChatType, req.MessageSend)
+	if err != nil {
+		return uint32(errorStatus(err)), err.Error(), nil
+	}
+	return http.StatusOK, "消息发送成功", result
+}
+
+// AckController 确认当前连接的设备已收到消息，确认后的消息不再向该设备重新投递
+func AckController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	var req request.MessageAck
+	if err := json.Unmarshal(message, &req); err != nil {
+		return http.StatusBadRequest, err.Error(), nil
+	}
+	if len(req.MessageIDs) == 0 {
+		return http.StatusBadRequest, "消息ID不能为空", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsCommandTimeout)
+	defer cancel()
+
+	acked, err := delivery.Ack(ctx, strconv.Itoa(int(client.UserID)), client.AppID, req.MessageIDs...)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error(), nil
+	}
+	return http.StatusOK, "确认成功", gin.H{"acked": acked}
+}
+
+// TypingController 通知会话中其他在线参与者自己正在输入，按发送者和会话限流，不保存也不进入离线队列
+func TypingController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	var req request.WsTyping
+	if err := json.Unmarshal(message, &req); err != nil {
+		return http.StatusBadRequest, err.Error(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsCommandTimeout)
+	defer cancel()
+
+	chatType, targetID, err := parseConversationID(req.Conv, int(client.UserID))
+	if err != nil {
+		if errors.Is(err, errNotParticipant) {
+			return http.StatusForbidden, err.Error(), nil
+		}
+		return http.StatusBadRequest, err.Error(), nil
+	}
+	if err := checkConversationAccess(ctx, int(client.UserID), chatType, targetID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			return http.StatusForbidden, err.Error(), nil
+		}
+		return http.StatusInternalServerError, err.Error(), nil
+	}
+
+	allowed, err := database.GetRedisClient().SetNX(ctx, typingLimitKey(client.UserID, req.Conv), 1, typingInterval).Result()
+	if err != nil {
+		return http.StatusInternalServerError, err.Error(), nil
+	}
+	if !allowed {
+		return http.StatusTooManyRequests, "发送过于频繁", nil
+	}
+
+	userIDStr := strconv.Itoa(int(client.UserID))
+	participants, err := conversationParticipants(ctx, model.MyMessage{
+		ChatType:   chatType,
+		UserFrom:   userIDStr,
+		SendTarget: strconv.Itoa(targetID),
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err.Error(), nil
+	}
+	others := make([]string, 0, len(participants))
+	for _, participant := range participants {
+		if participant != userIDStr {
+			others = append(others, participant)
+		}
+	}
+	groupID := 0
+	if chatType == model.GROUP_CHAT {
+		groupID = targetID
+	}
+	sendTyping(ctx, groupID, others, gin.H{"conv_id": req.Conv, "user_id": client.UserID})
+	return http.StatusOK, "", nil
+}
+
+// sendTyping 推送输入状态：本节点上的参与者直接写入连接，其他节点上的在线参与者按节点通过 gRPC 推送
+// 离线用户直接忽略
+func sendTyping(ctx context.Context, groupID int, userIDs []string, data interface{}) {
+	env, err := delivery.NewEnvelope("", uuid.NewString(), delivery.EventTyping, data)
+	if err != nil {
+		log.Printf("构造输入状态事件失败: %v", err)
+		return
+	}
+	env.Transient = true
+
+	remote := make([]string, 0, len(userIDs))
+	for _, userIDStr := range userIDs {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			continue
+		}
+		env.UserID = userIDStr
+		if websocket.SendToUser(uint(userID), env) == 0 {
+			remote = append(remote, userIDStr)
+		}
+	}
+	if len(remote) > 0 {
+		delivery.NotifyGroup(ctx, groupID, remote, env)
+	}
+}
 
+// SyncController 通过 WebSocket 增量同步会话消息
+func SyncController(client *websocket.Client, seq string, message []byte) (code uint32, msg string, data interface{}) {
+	var req request.WsSync
+	if err := json.Unmarshal(message, &req); err != nil {
+		return http.StatusBadRequest, err.Error(), nil
+	}
+	if req.AfterSeq < 0 {
+		return http.StatusBadRequest, "无效的 after_seq 参数", nil
 	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wsCommandTimeout)
+	defer cancel()
 
-	return
+	result, err := syncConversation(ctx, client.UserID, req.Conv, req.AfterSeq, limit)
+	if err != nil {
+		return uint32(errorStatus(err)), err.Error(), nil
+	}
+	return http.StatusOK, "", result
 }
@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const maxAnnouncementRunes = 2000 // 群公告最大字符数
+
+// groupCacheKey 群组信息缓存，与 isgroupexist 使用同一个键
+func groupCacheKey(groupID int) string {
+	return "group:" + strconv.Itoa(groupID)
+}
+
+// parseGroupParam 解析路径中的群组ID
+func parseGroupParam(ctx *gin.Context) (int, error) {
+	groupID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || groupID <= 0 {
+		return 0, errors.New("无效的群组ID")
+	}
+	return groupID, nil
+}
+
+// announcementEvent 构造推送给成员的群公告事件
+func announcementEvent(group model.Group) gin.H {
+	return gin.H{
+		"group_id":     group.GroupID,
+		"content":      group.Announcement,
+		"announced_by": group.AnnouncementBy,
+		"version":      group.AnnouncementAt,
+	}
+}
+
+// PostAnnouncement 群主或管理员发布群公告，新公告推送给每个成员，成员需要重新确认
+func PostAnnouncement(ctx *gin.Context) {
+	var req request.GroupAnnouncement
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Content == "" || utf8.RuneCountInString(req.Content) > maxAnnouncementRunes {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "公告内容为空或过长"})
+		return
+	}
+	groupID, err := parseGroupParam(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	err, group := isgroupexist(ctx, groupID)
+	if err != nil {
+		return
+	}
+	role, err := getGroupRole(ctx, int(UserID), groupID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isGroupManager(role) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有群主或管理员可以发布群公告"})
+		return
+	}
+
+	group.Announcement = req.Content
+	group.AnnouncementBy = int(UserID)
+	group.AnnouncementAt = time.Now().UnixMilli()
+	if err := database.GetDB().Model(&model.Group{}).Where("group_id = ?", groupID).
+		Updates(map[string]interface{}{
+			"announcement":    group.Announcement,
+			"announcement_by": group.AnnouncementBy,
+			"announcement_at": group.AnnouncementAt,
+		}).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 删除群组缓存，下次读取时从数据库重建
+	if err := database.GetRedisClient().Del(ctx, groupCacheKey(groupID)).Err(); err != nil {
+		log.Printf("删除群组 %d 缓存失败: %v", groupID, err)
+	}
+
+	// 公告进入每个成员的待确认队列，离线成员上线后也能收到
+	members, err := loadGroupMembers(ctx, database.GetRedisClient(), groupID)
+	if err != nil {
+		log.Printf("获取群 %d 成员失败，公告未推送: %v", groupID, err)
+	}
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = strconv.Itoa(member.UserID)
+	}
+	delivered := broadcastEvent(ctx, userIDs, delivery.EventAnnouncement, announcementEvent(group))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "群公告发布成功",
+		"version":   group.AnnouncementAt,
+		"delivered": delivered,
+	})
+}
+
+// GetAnnouncement 获取群公告以及调用者是否已确认当前版本
+func GetAnnouncement(ctx *gin.Context) {
+	groupID, err := parseGroupParam(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	if err := checkConversationAccess(ctx, int(UserID), model.GROUP_CHAT, groupID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err, group := isgroupexist(ctx, groupID)
+	if err != nil {
+		return
+	}
+
+	confirmed := true
+	if group.AnnouncementAt > 0 {
+		var confirm model.GroupAnnouncementConfirm
+		err := database.GetDB().Where("group_id = ? AND user_id = ?", groupID, int(UserID)).First(&confirm).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		confirmed = confirm.Version >= group.AnnouncementAt
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"group_id":     group.GroupID,
+		"content":      group.Announcement,
+		"announced_by": group.AnnouncementBy,
+		"version":      group.AnnouncementAt,
+		"confirmed":    confirmed,
+	})
+}
+
+// ConfirmAnnouncement 成员确认已阅读当前版本的群公告
+func ConfirmAnnouncement(ctx *gin.Context) {
+	var req request.AnnouncementConfirm
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groupID, err := parseGroupParam(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	if err := checkConversationAccess(ctx, int(UserID), model.GROUP_CHAT, groupID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err, group := isgroupexist(ctx, groupID)
+	if err != nil {
+		return
+	}
+	if group.AnnouncementAt == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "该群没有公告"})
+		return
+	}
+	// 确认的版本不是最新时，客户端需要先拉取新公告
+	if req.Version != group.AnnouncementAt {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "群公告已更新", "version": group.AnnouncementAt})
+		return
+	}
+
+	confirm := model.GroupAnnouncementConfirm{GroupID: groupID, UserID: int(UserID), Version: req.Version}
+	if err := database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"version": req.Version, "updated_at": time.Now()}),
+	}).Create(&confirm).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "确认成功", "version": req.Version})
+}
+
+// deliverAnnouncementOnJoin 新成员入群时投递当前的群公告
+func deliverAnnouncementOnJoin(ctx context.Context, group model.Group, userID int) {
+	if group.AnnouncementAt == 0 {
+		return
+	}
+	broadcastEvent(ctx, []string{strconv.Itoa(userID)}, delivery.EventAnnouncement, announcementEvent(group))
+}
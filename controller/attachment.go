@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+const (
+	attachmentBackfillID    = 1           // 重建进度记录的主键，表中只有这一行
+	attachmentBackfillBatch = 500         // 重建引用计数时每批扫描的消息数
+	attachmentBackfillRetry = time.Minute // 重建失败后的重试间隔
+	attachmentSweepBatch    = 500         // 每次清理的无引用对象数
+)
+
+var errAttachmentGone = errors.New("消息引用的附件已被删除")
+
+// attachmentKeys 提取消息引用的 MinIO 对象名，合并转发中沿用的原消息附件同样计入
+// 同一对象被引用多次时返回多次，引用计数按次数增减
+func attachmentKeys(msg model.MyMessage) []string {
+	return payloadAttachmentKeys(msg.Type, msg.Content)
+}
+
+func payloadAttachmentKeys(msgType model.MessageType, content string) []string {
+	switch msgType {
+	case model.IMAGE:
+		var p model.ImagePayload
+		if json.Unmarshal([]byte(content), &p) == nil {
+			keys := []string{p.ObjectKey}
+			if p.ThumbnailKey != "" {
+				keys = append(keys, p.ThumbnailKey)
+			}
+			return keys
+		}
+	case model.FILE:
+		var p model.FilePayload
+		if json.Unmarshal([]byte(content), &p) == nil {
+			return []string{p.ObjectKey}
+		}
+	case model.VOICE:
+		var p model.VoicePayload
+		if json.Unmarshal([]byte(content), &p) == nil {
+			return []string{p.ObjectKey}
+		}
+	case model.MERGED_FORWARD:
+		var p model.MergedForwardPayload
+		if json.Unmarshal([]byte(content), &p) == nil {
+			var keys []string
+			for _, item := range p.Items {
+				keys = append(keys, payloadAttachmentKeys(item.Type, item.Content)...)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+// countKeys 统计每个对象名出现的次数，忽略空对象名
+func countKeys(objectKeys []string) map[string]int64 {
+	counts := make(map[string]int64, len(objectKeys))
+	for _, objectKey := range objectKeys {
+		if objectKey != "" {
+			counts[objectKey]++
+		}
+	}
+	return counts
+}
+
+// addAttachmentRefs 在事务中增加附件的引用计数
+// 先锁定计数行再检查文件记录，与 releaseAttachmentRefs 的加锁顺序一致：并发清理先提交时文件记录已被删除，返回 errAttachmentGone
+func addAttachmentRefs(tx *gorm.DB, objectKeys []string) error {
+	counts := countKeys(objectKeys)
+	if len(counts) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(counts))
+	for objectKey, count := range counts {
+		ref := model.AttachmentRef{ObjectKey: objectKey, RefCount: count}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "object_key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + ?", count)}),
+		}).Create(&ref).Error; err != nil {
+			return err
+		}
+		keys = append(keys, objectKey)
+	}
+
+	var files int64
+	if err := tx.Table("files").
+		Where("name IN ? AND bucket = ? AND deleted_at IS NULL", keys, viper.GetString("minio.bucket")).
+		Distinct("name").Count(&files).Error; err != nil {
+		return err
+	}
+	if files < int64(len(keys)) {
+		return errAttachmentGone
+	}
+	return nil
+}
+
+// releaseMessageAttachments 在事务中释放消息对附件的引用，返回计数减到0、可以删除的对象名
+// 这些对象的计数行和文件记录在同一事务中删除，事务提交后再调用 removeAttachmentObjects 删除对象
+// 引用计数重建期间，尚未扫描到的历史消息还没有计数，释放时跳过；其余对象只减少计数，减到0也不删除，
+// 因为它们可能仍被尚未扫描到的消息引用，重建完成后由 sweepOrphanedAttachments 删除
+func releaseMessageAttachments(tx *gorm.DB, messages ...model.MyMessage) ([]string, error) {
+	// 与重建任务的排他锁互斥，保证每条消息要么已经计数、要么会在之后被扫描到
+	var state model.AttachmentBackfill
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ?", attachmentBackfillID).Take(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 重建尚未开始，无法判断哪些消息已经计数，不释放任何引用，对象只会晚些删除
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var objectKeys []string
+	for _, msg := range messages {
+		if !state.Done && msg.ID > state.LastID && msg.ID <= state.EndID {
+			continue
+		}
+		objectKeys = append(objectKeys, attachmentKeys(msg)...)
+	}
+	counts := countKeys(objectKeys)
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(counts))
+	for objectKey, count := range counts {
+		if err := tx.Model(&model.AttachmentRef{}).Where("object_key = ?", objectKey).
+			Update("ref_count", gorm.Expr("ref_count - ?", count)).Error; err != nil {
+			return nil, err
+		}
+		keys = append(keys, objectKey)
+	}
+	if !state.Done {
+		return nil, nil
+	}
+	return deleteOrphanedRefs(tx, keys)
+}
+
+// deleteOrphanedRefs 删除计数已减到0的计数行和对应的文件记录，返回被删除的对象名
+// objectKeys 为 nil 时不限对象，每次最多删除 attachmentSweepBatch 个；没有计数行的对象不删除，它们的引用情况未知
+func deleteOrphanedRefs(tx *gorm.DB, objectKeys []string) ([]string, error) {
+	query := tx.Model(&model.AttachmentRef{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("ref_count <= 0")
+	if objectKeys != nil {
+		query = query.Where("object_key IN ?", objectKeys)
+	} else {
+		query = query.Limit(attachmentSweepBatch)
+	}
+	var orphaned []string
+	if err := query.Pluck("object_key", &orphaned).Error; err != nil {
+		return nil, err
+	}
+	if len(orphaned) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("object_key IN ?", orphaned).Delete(&model.AttachmentRef{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Table("files").
+		Where("name IN ? AND bucket = ?", orphaned, viper.GetString("minio.bucket")).
+		Delete(&model.File{}).Error; err != nil {
+		return nil, err
+	}
+	return orphaned, nil
+}
+
+// sweepOrphanedAttachments 删除引用计数重建期间减到0但没有删除的对象，重建完成前不做任何事
+// 由 PurgeExpiredMessages 在持有清理锁时调用
+func sweepOrphanedAttachments(ctx context.Context) {
+	var orphaned []string
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var state model.AttachmentBackfill
+		err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("id = ?", attachmentBackfillID).Take(&state).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !state.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		orphaned, err = deleteOrphanedRefs(tx, nil)
+		return err
+	})
+	if err != nil {
+		log.Printf("清理无引用附件失败: %v", err)
+		return
+	}
+	removeAttachmentObjects(ctx, orphaned)
+}
+
+// removeAttachmentObjects 从 MinIO 删除已经没有消息引用的对象
+func removeAttachmentObjects(ctx context.Context, objectKeys []string) {
+	bucketName := viper.GetString("minio.bucket")
+	minioCli := database.GetMinioClisnt()
+	for _, objectKey := range objectKeys {
+		if err := minioCli.RemoveObject(ctx, bucketName, objectKey, minio.RemoveObjectOptions{}); err != nil {
+			log.Printf("删除附件 %s 失败: %v", objectKey, err)
+		}
+	}
+}
+
+// BackfillAttachmentRefs 从已有消息重建附件引用计数，由 utils.InitPoll 在后台启动，每个节点都会运行
+// 进度保存在 attachment_backfills 表中：每批消息的计数和进度在同一事务中提交，并以排他锁锁定进度行，
+// 多个节点同时运行或进程中途退出后重新启动都不会重复计数；出错后等待一段时间从上次提交的位置继续
+// 进度记录创建之前已经按发送计数的消息会再被计数一次，这些对象不会被删除，只在升级部署的窗口内发生
+func BackfillAttachmentRefs() {
+	db := database.GetDB()
+	for {
+		err := initAttachmentBackfill(db)
+		if err == nil {
+			break
+		}
+		log.Printf("创建附件引用计数重建进度失败: %v", err)
+		time.Sleep(attachmentBackfillRetry)
+	}
+	for {
+		done, err := backfillAttachmentBatch(db)
+		if err != nil {
+			log.Printf("重建附件引用计数失败，稍后从上次的进度继续: %v", err)
+			time.Sleep(attachmentBackfillRetry)
+			continue
+		}
+		if done {
+			return
+		}
+	}
+}
+
+// initAttachmentBackfill 首次运行时创建进度记录，只扫描此时已有的消息
+func initAttachmentBackfill(db *gorm.DB) error {
+	var maxID uint
+	if err := db.Model(&model.MyMessage{}).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return err
+	}
+	// 多个节点同时创建时以先创建的为准
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.AttachmentBackfill{ID: attachmentBackfillID, EndID: maxID}).Error
+}
+
+// backfillAttachmentBatch 在一个事务中为下一批消息计数并推进进度，返回是否已经重建完成
+func backfillAttachmentBatch(db *gorm.DB) (bool, error) {
+	done := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var state model.AttachmentBackfill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", attachmentBackfillID).Take(&state).Error; err != nil {
+			return err
+		}
+		if state.Done {
+			done = true
+			return nil
+		}
+
+		var messages []model.MyMessage
+		if err := tx.Select("id, type, content").
+			Where("id > ? AND id <= ? AND type IN ?", state.LastID, state.EndID,
+				[]model.MessageType{model.IMAGE, model.FILE, model.VOICE, model.MERGED_FORWARD}).
+			Order("id ASC").Limit(attachmentBackfillBatch).Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			done = true
+			log.Printf("附件引用计数重建完成")
+			return tx.Model(&state).Update("done", true).Error
+		}
+
+		var keys []string
+		for _, msg := range messages {
+			keys = append(keys, attachmentKeys(msg)...)
+		}
+		// 历史消息引用的对象可能已被删除，保留计数即可
+		if err := addAttachmentRefs(tx, keys); err != nil && !errors.Is(err, errAttachmentGone) {
+			return err
+		}
+		return tx.Model(&state).Update("last_id", messages[len(messages)-1].ID).Error
+	})
+	return done, err
+}
@@ -18,86 +18,6 @@ import (
 	"time"
 )
 
-func PushMessage(ctx context.Context, user model.User) {
-	redisClient := database.GetRedisClient()
-	userIDStr := strconv.Itoa(int(user.ID))
-
-	// 消息队列键名
-	messageKey := fmt.Sprintf("messages:%s", userIDStr)
-
-	// 获取待发送消息
-	messages, err := redisClient.LRange(ctx, messageKey, 0, -1).Result()
-	if err != nil {
-		log.Printf("获取用户 %s 消息失败: %v", userIDStr, err)
-		return
-	}
-
-	if len(messages) == 0 {
-		log.Printf("用户 %s 没有待发送消息", userIDStr)
-		return
-	}
-
-	// 状态键
-	statusKey := fmt.Sprintf("%s", userIDStr)
-
-	var retry int
-	maxRetries := 10
-	retryDelay := 100 * time.Millisecond
-
-	for retry < maxRetries {
-		// 检查用户是否在线
-		status, err := redisClient.HGet(ctx, statusKey, "status").Result()
-		if err == redis.Nil {
-			log.Printf("用户 %s 状态未设置", userIDStr)
-			time.Sleep(retryDelay)
-			retry++
-			continue
-		} else if err != nil {
-			log.Printf("获取用户 %s 状态出错: %v", userIDStr, err)
-			return
-		}
-
-		if status == "online" {
-			// 获取用户IP
-			ipKey := fmt.Sprintf("ip%s", userIDStr)
-			targetIP, err := redisClient.Get(ctx, ipKey).Result()
-			if err == redis.Nil {
-				log.Printf("用户 %s IP未设置", userIDStr)
-				return
-			} else if err != nil {
-				log.Printf("获取用户 %s IP出错: %v", userIDStr, err)
-				return
-			}
-
-			// 推送消息到目标队列
-			queueName := fmt.Sprintf("message_queue%s", targetIP)
-			pipe := redisClient.Pipeline()
-
-			for _, msg := range messages {
-				log.Printf("推送消息到队列 %s: %s", queueName, msg)
-				pipe.LPush(ctx, queueName, msg)
-			}
-
-			_, err = pipe.Exec(ctx)
-			if err != nil {
-				log.Printf("批量推送消息失败: %v", err)
-				return
-			}
-
-			// 成功后可以选择清除原消息队列
-			redisClient.Del(ctx, messageKey)
-			log.Printf("成功推送 %d 条消息到用户 %s", len(messages), userIDStr)
-			return
-		}
-
-		log.Printf("用户 %s 不在线，等待重试 (%d/%d)", userIDStr, retry+1, maxRetries)
-		time.Sleep(retryDelay)
-		retry++
-	}
-
-	log.Printf("推送消息失败：用户 %s 在最大重试次数内未上线", userIDStr)
-}
-
 // 旁路缓存好友添加和处理
 func FriendAdd(ctx *gin.Context) {
 	var req model.FriendAdd
@@ -369,6 +289,8 @@ func GroupAddRedis(ctx *gin.Context) {
 	if err != nil {
 		log.Printf("Error caching group member: %v", err)
 	}
+	// 新成员入群时推送当前的群公告
+	deliverAnnouncementOnJoin(ctx, group, req.UserID)
 	// 假设申请已发送
 	ctx.JSON(http.StatusOK, gin.H{"message": "Group join request sent successfully"})
 }
@@ -616,7 +538,7 @@ func isgroupexist(ctx *gin.Context, GroupID int) (error, model.Group) {
 }
 
 // IsGroupMember 检查用户是否是群组成员
-func IsGroupMember(ctx *gin.Context, userID int, groupID int) (bool, error) {
+func IsGroupMember(ctx context.Context, userID int, groupID int) (bool, error) {
 	db := database.GetDB()
 	redisCli := database.GetRedisClient()
 
@@ -677,7 +599,7 @@ func IsGroupMember(ctx *gin.Context, userID int, groupID int) (bool, error) {
 
 // IsFriends 检查两个用户是否已经是好友关系
 // IsFriends 检查两个用户是否已经是好友关系
-func IsFriends(ctx *gin.Context, userID, friendID int) (bool, error) {
+func IsFriends(ctx context.Context, userID, friendID int) (bool, error) {
 	db := database.GetDB()
 	redisCli := database.GetRedisClient()
 
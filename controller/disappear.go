@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	minConversationTTL = 5                     // 阅后即焚最短保留时间（秒）
+	maxConversationTTL = 7 * 24 * 60 * 60      // 阅后即焚最长保留时间（秒）
+	purgeBatchSize     = 500                   // 每次清理的过期消息数
+	purgeClaimKey      = "purge_expired_claim" // 清理任务的认领锁，同一时间只有一个节点清理
+	purgeClaimTTL      = 5 * time.Minute
+)
+
+// timerCacheKey 会话阅后即焚时间的缓存
+func timerCacheKey(convID string) string {
+	return "conv_timer:" + convID
+}
+
+// getConversationTTL 获取会话的阅后即焚时间（秒），0表示关闭，优先读缓存
+func getConversationTTL(ctx context.Context, convID string) (int64, error) {
+	redisCli := database.GetRedisClient()
+	cached, err := redisCli.Get(ctx, timerCacheKey(convID)).Int64()
+	if err == nil {
+		return cached, nil
+	}
+	if err != redis.Nil {
+		log.Printf("读取会话 %s 阅后即焚缓存失败: %v", convID, err)
+	}
+
+	var timer model.ConversationTimer
+	if err := database.GetDB().Where("conv_id = ?", convID).First(&timer).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	// 未设置的会话也缓存为0，避免每条消息都查询数据库
+	if err := redisCli.Set(ctx, timerCacheKey(convID), timer.TTL, 7*24*time.Hour).Err(); err != nil {
+		log.Printf("缓存会话 %s 阅后即焚设置失败: %v", convID, err)
+	}
+	return timer.TTL, nil
+}
+
+// applyConversationTTL 会话开启阅后即焚时为消息设置过期时间
+func applyConversationTTL(ctx context.Context, msg *model.MyMessage) {
+	ttl, err := getConversationTTL(ctx, msg.ConvID)
+	if err != nil {
+		log.Printf("获取会话 %s 阅后即焚设置失败: %v", msg.ConvID, err)
+		return
+	}
+	if ttl > 0 {
+		msg.ExpireAt = msg.SendTime + ttl
+	}
+}
+
+// GetConversationTimer 获取会话的阅后即焚设置
+func GetConversationTimer(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	convID := ctx.Param("id")
+	if !checkConversationParam(ctx, int(UserID), convID) {
+		return
+	}
+
+	ttl, err := getConversationTTL(ctx, convID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"conv_id": convID, "ttl": ttl})
+}
+
+// SetConversationTimer 设置会话的阅后即焚时间，只对之后发送的消息生效
+// 单聊双方都可以设置，群聊只有群主或管理员可以设置
+func SetConversationTimer(ctx *gin.Context) {
+	var req request.ConversationTimer
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TTL != 0 && (req.TTL < minConversationTTL || req.TTL > maxConversationTTL) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "阅后即焚时间超出范围"})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	convID := ctx.Param("id")
+	if !checkConversationParam(ctx, int(UserID), convID) {
+		return
+	}
+	chatType, targetID, _ := parseConversationID(convID, int(UserID))
+	if chatType == model.GROUP_CHAT {
+		role, err := getGroupRole(ctx, int(UserID), targetID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !isGroupManager(role) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "只有群主或管理员可以设置阅后即焚"})
+			return
+		}
+	}
+
+	timer := model.ConversationTimer{ConvID: convID, TTL: req.TTL, UpdatedBy: int(UserID)}
+	if err := database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conv_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ttl", "updated_by", "updated_at"}),
+	}).Create(&timer).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.GetRedisClient().Set(ctx, timerCacheKey(convID), req.TTL, 7*24*time.Hour).Err(); err != nil {
+		log.Printf("缓存会话 %s 阅后即焚设置失败: %v", convID, err)
+	}
+
+	participants, err := conversationParticipants(ctx, model.MyMessage{
+		ChatType:   chatType,
+		UserFrom:   strconv.Itoa(int(UserID)),
+		SendTarget: strconv.Itoa(targetID),
+	})
+	if err != nil {
+		log.Printf("获取会话 %s 参与者失败: %v", convID, err)
+	}
+	broadcastEvent(ctx, participants, delivery.EventTimer, gin.H{
+		"conv_id": convID,
+		"ttl":     req.TTL,
+		"by":      int(UserID),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "设置成功", "conv_id": convID, "ttl": req.TTL})
+}
+
+// checkConversationParam 检查路径中的会话ID有效且调用者是参与者，失败时已写入响应
+func checkConversationParam(ctx *gin.Context, userID int, convID string) bool {
+	chatType, targetID, err := parseConversationID(convID, userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := checkConversationAccess(ctx, userID, chatType, targetID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// releaseClaimScript 锁的值仍是自己的令牌时才删除锁
+// KEYS: 锁；ARGV: 认领时写入的令牌
+var releaseClaimScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// PurgeExpiredMessages 删除已过期的阅后即焚消息及其附件，并通知参与者删除本地副本
+// 由 utils.InitPoll 中的定时任务调用，多个节点同时运行时只有认领到锁的节点执行
+func PurgeExpiredMessages() {
+	ctx := context.Background()
+	db := database.GetDB()
+	redisCli := database.GetRedisClient()
+
+	// 锁的值为本次认领的随机令牌，释放时只删除自己持有的锁；
+	// 本次清理超过 purgeClaimTTL 时锁可能已经过期并被其他节点认领，不能直接删除
+	token := uuid.NewString()
+	claimed, err := redisCli.SetNX(ctx, purgeClaimKey, token, purgeClaimTTL).Result()
+	if err != nil {
+		log.Printf("认领过期消息清理任务失败: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	defer func() {
+		if err := releaseClaimScript.Run(ctx, redisCli, []string{purgeClaimKey}, token).Err(); err != nil {
+			log.Printf("释放过期消息清理任务的锁失败: %v", err)
+		}
+	}()
+
+	// 附件引用计数重建期间减到0的对象在重建完成后才删除
+	sweepOrphanedAttachments(ctx)
+
+	var expired []model.MyMessage
+	if err := db.Where("expire_at > 0 AND expire_at <= ?", time.Now().Unix()).
+		Order("id ASC").Limit(purgeBatchSize).Find(&expired).Error; err != nil {
+		log.Printf("查询过期消息失败: %v", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+	messageIDs := messageIDsOf(expired)
+
+	// 消息及其编辑历史、表情回应、提及和置顶记录一起删除，同时释放消息对附件的引用
+	var orphaned []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 锁定后重新读取，以删除时的内容为准释放引用，避免与并发的撤回重复释放
+		var locked []model.MyMessage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("message_id IN ?", messageIDs).Find(&locked).Error; err != nil {
+			return err
+		}
+		for _, related := range []interface{}{
+			&model.MessageEdit{}, &model.MessageReaction{}, &model.MessageMention{}, &model.PinnedMessage{},
+		} {
+			if err := tx.Unscoped().Where("message_id IN ?", messageIDs).Delete(related).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("message_id IN ?", messageIDs).Delete(&model.MyMessage{}).Error; err != nil {
+			return err
+		}
+		var err error
+		orphaned, err = releaseMessageAttachments(tx, locked...)
+		return err
+	})
+	if err != nil {
+		log.Printf("删除过期消息失败: %v", err)
+		return
+	}
+
+	for _, messageID := range messageIDs {
+		redisCli.Del(ctx, reactionKey(messageID), reactionVersionKey(messageID))
+	}
+
+	removeAttachmentObjects(ctx, orphaned)
+
+	// 按会话通知参与者删除本地副本
+	byConv := make(map[string][]model.MyMessage)
+	for _, msg := range expired {
+		byConv[msg.ConvID] = append(byConv[msg.ConvID], msg)
+	}
+	for convID, messages := range byConv {
+		participants, err := conversationParticipants(ctx, messages[0])
+		if err != nil {
+			log.Printf("获取会话 %s 参与者失败: %v", convID, err)
+			continue
+		}
+		purgedIDs := messageIDsOf(messages)
+		// 尚未确认的副本也不再投递
+		for _, userID := range participants {
+			if _, err := delivery.Remove(ctx, userID, purgedIDs...); err != nil {
+				log.Printf("移除用户 %s 待确认的过期消息失败: %v", userID, err)
+			}
+		}
+		broadcastEvent(ctx, participants, delivery.EventPurge, gin.H{
+			"conv_id":     convID,
+			"message_ids": purgedIDs,
+		})
+	}
+	log.Printf("已清理 %d 条过期消息", len(expired))
+}
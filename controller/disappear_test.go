@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/helpleness/IMChatAdmin/database"
+)
+
+func TestReleaseClaimKeepsOtherHoldersLock(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	redisCli := database.GetRedisClient()
+
+	// 本节点的锁已经过期，其他节点重新认领
+	if err := mr.Set(purgeClaimKey, "other"); err != nil {
+		t.Fatal(err)
+	}
+	released, err := releaseClaimScript.Run(ctx, redisCli, []string{purgeClaimKey}, "mine").Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released != 0 {
+		t.Fatal("不应删除其他节点持有的锁")
+	}
+	if got, _ := mr.Get(purgeClaimKey); got != "other" {
+		t.Errorf("锁 = %q, want other", got)
+	}
+
+	released, err = releaseClaimScript.Run(ctx, redisCli, []string{purgeClaimKey}, "other").Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released != 1 || mr.Exists(purgeClaimKey) {
+		t.Error("持有者应当能释放自己的锁")
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/service/registry"
+	"github.com/spf13/viper"
+	"net/http"
+)
+
+// GetWsEndpoint 为客户端分配 WebSocket 连接节点
+// websocket.placement 为 hash 时按用户ID一致性哈希，否则分配连接数最少的健康节点
+func GetWsEndpoint(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	placement := viper.GetString("websocket.placement")
+	node, err := registry.Pick(ctx, UserID, placement)
+	if errors.Is(err, registry.ErrNoNode) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"node": node.Addr,
+		"ip":   node.IP,
+		"port": node.Port,
+		"url":  fmt.Sprintf("ws://%s:%s/ws/default.io", node.IP, node.Port),
+	})
+}
@@ -41,9 +41,11 @@ func UploadFile(ctx *gin.Context) {
 	}
 	//数据库
 	DB := database.GetDB()
+	ID, _ := ctx.Get("userid")
 	newFile := &model.File{
 		Name:   name,
 		Bucket: bucketName,
+		UserID: ID.(uint),
 	}
 	//在数据库打上一条文件上传的命令
 	DB.Table("files").Create(newFile)
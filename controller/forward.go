@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxForwardMessages = 100    // 单次最多转发的消息数
+	maxForwardTitle    = 64     // 合并转发标题的最大字符数
+	defaultForwardName = "聊天记录" // 合并转发的默认标题
+)
+
+// ForwardMessages 将一条或多条消息转发到好友单聊或群聊
+// merged 为 true 时多条消息合并为一条聊天记录消息，否则逐条转发；附件只引用原对象，不会在 MinIO 中复制
+func ForwardMessages(ctx *gin.Context) {
+	var req request.MessageForward
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	if len(req.MessageIDs) == 0 || len(req.MessageIDs) > maxForwardMessages {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "转发的消息数量不正确"})
+		return
+	}
+	if req.ChatType != model.SINGLE_CHAT && req.ChatType != model.GROUP_CHAT {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "未知的会话类型"})
+		return
+	}
+	if req.SendTarget <= 0 || (req.ChatType == model.SINGLE_CHAT && req.SendTarget == int(UserID)) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "接收者ID不正确"})
+		return
+	}
+	if utf8.RuneCountInString(req.Title) > maxForwardTitle {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "标题过长"})
+		return
+	}
+
+	// 调用者必须能在目标会话中发言
+	if err := checkConversationAccess(ctx, int(UserID), req.ChatType, req.SendTarget); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "你不能在目标会话中发送消息"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	originals, err := loadForwardSources(ctx, int(UserID), req.MessageIDs)
+	if err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var messages []model.MyMessage
+	if req.Merged && len(originals) > 1 {
+		msg, err := buildMergedForward(int(UserID), req, originals)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		messages = append(messages, msg)
+	} else {
+		for _, original := range originals {
+			messages = append(messages, model.MyMessage{
+				MessageID:     uuid.NewString(),
+				UserFrom:      strconv.Itoa(int(UserID)),
+				SendTarget:    strconv.Itoa(req.SendTarget),
+				Content:       original.Content,
+				Type:          original.Type,
+				ChatType:      req.ChatType,
+				SendTime:      time.Now().Unix(),
+				ForwardedFrom: original.MessageID,
+			})
+		}
+	}
+
+	messageIDs := make([]string, 0, len(messages))
+	for i := range messages {
+		if err := dispatchMessage(ctx, &messages[i]); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "message_ids": messageIDs})
+			return
+		}
+		messageIDs = append(messageIDs, messages[i].MessageID)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "转发成功", "message_ids": messageIDs})
+}
+
+// loadForwardSources 按发送顺序加载要转发的原消息，并检查调用者可以查看每一条消息
+func loadForwardSources(ctx *gin.Context, userID int, messageIDs []string) ([]model.MyMessage, error) {
+	var originals []model.MyMessage
+	if err := database.GetDB().Where("message_id IN ?", messageIDs).Order("id ASC").Find(&originals).Error; err != nil {
+		return nil, err
+	}
+	if len(originals) != len(uniqueStrings(messageIDs)) {
+		return nil, errors.New("部分消息不存在")
+	}
+
+	// 同一会话只检查一次访问权限
+	checked := make(map[string]bool)
+	for _, original := range originals {
+		if original.Recalled {
+			return nil, errors.New("不能转发已撤回的消息")
+		}
+		if !isChatMessageType(original.Type) && original.Type != model.MERGED_FORWARD {
+			return nil, errors.New("该类型的消息不能转发")
+		}
+		if checked[original.ConvID] {
+			continue
+		}
+		if err := checkMessageAccess(ctx, userID, original); err != nil {
+			return nil, err
+		}
+		checked[original.ConvID] = true
+	}
+	return originals, nil
+}
+
+// buildMergedForward 将多条消息合并为一条聊天记录消息
+func buildMergedForward(userID int, req request.MessageForward, originals []model.MyMessage) (model.MyMessage, error) {
+	title := req.Title
+	if title == "" {
+		title = defaultForwardName
+	}
+	payload := model.MergedForwardPayload{
+		Title: title,
+		Items: make([]model.MergedForwardItem, len(originals)),
+	}
+	for i, original := range originals {
+		payload.Items[i] = model.MergedForwardItem{
+			MessageID: original.MessageID,
+			UserFrom:  original.UserFrom,
+			Type:      original.Type,
+			Content:   original.Content,
+			SendTime:  original.SendTime,
+		}
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return model.MyMessage{}, err
+	}
+
+	return model.MyMessage{
+		MessageID:  uuid.NewString(),
+		UserFrom:   strconv.Itoa(userID),
+		SendTarget: strconv.Itoa(req.SendTarget),
+		Content:    string(content),
+		Type:       model.MERGED_FORWARD,
+		ChatType:   req.ChatType,
+		SendTime:   time.Now().Unix(),
+	}, nil
+}
+
+// dispatchMessage 保存消息并按会话类型投递给接收者或群成员
+func dispatchMessage(ctx context.Context, msg *model.MyMessage) error {
+	if err := saveMessage(ctx, msg); err != nil {
+		return err
+	}
+
+	if msg.ChatType == model.SINGLE_CHAT {
+		if err := deliverMessage(ctx, msg.SendTarget, *msg); err != nil {
+			log.Printf("投递消息 %s 到用户 %s 失败: %v", msg.MessageID, msg.SendTarget, err)
+		}
+		return nil
+	}
+
+	groupID, _ := strconv.Atoi(msg.SendTarget)
+	members, err := loadGroupMembers(ctx, database.GetRedisClient(), groupID)
+	if err != nil {
+		log.Printf("获取群 %d 成员失败，消息 %s 未扇出: %v", groupID, msg.MessageID, err)
+		return nil
+	}
+	fanoutGroupMessage(ctx, members, *msg, nil)
+	return nil
+}
+
+// uniqueStrings 去除重复的字符串，保持原有顺序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
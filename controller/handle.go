@@ -265,6 +265,9 @@ func HandleGroupApplication(ctx *gin.Context) {
 		return
 	}
 
+	// 新成员入群时推送当前的群公告
+	deliverAnnouncementOnJoin(ctx, group, req.UserID)
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "群组申请处理成功"})
 
 	// 缓存清理
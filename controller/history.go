@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultHistoryLimit = 20  // 默认每页消息条数
+	maxHistoryLimit     = 100 // 每页消息条数上限
+)
+
+// errNotParticipant 表示调用者不是会话参与者
+var errNotParticipant = errors.New("你不是该会话的参与者")
+
+// GetMessageHistory 按时间倒序分页获取会话历史消息，使用游标而不是 OFFSET 翻页
+func GetMessageHistory(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	chatType, targetID, err := parseConversation(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := parseLimit(ctx.Query("limit"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var before uint
+	if cursor := ctx.Query("before"); cursor != "" {
+		before, err = decodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的游标"})
+			return
+		}
+	}
+
+	// 只有会话参与者才能读取历史消息
+	if err := checkConversationAccess(ctx, int(UserID), chatType, targetID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	query := db.Model(&model.MyMessage{}).Scopes(conversationScope(chatType, int(UserID), targetID))
+	if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	// 多取一条用于判断是否还有更早的消息
+	var messages []model.MyMessage
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&messages).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	nextCursor := ""
+	if hasMore {
+		nextCursor = encodeCursor(messages[len(messages)-1].ID)
+	}
+
+	// 附带每条消息的表情回应计数
+	reactions, err := getReactionCounts(ctx, messageIDsOf(messages))
+	if err != nil {
+		log.Printf("获取表情计数失败: %v", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"messages":    messages,
+		"reactions":   reactions,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	})
+}
+
+// parseConversation 从 peer 或 group 参数中解析会话类型和会话对象ID
+func parseConversation(ctx *gin.Context) (model.ChatType, int, error) {
+	peer := ctx.Query("peer")
+	group := ctx.Query("group")
+	if (peer == "") == (group == "") {
+		return 0, 0, errors.New("peer 和 group 必须且只能指定一个")
+	}
+
+	if peer != "" {
+		peerID, err := strconv.Atoi(peer)
+		if err != nil || peerID <= 0 {
+			return 0, 0, errors.New("无效的好友ID")
+		}
+		return model.SINGLE_CHAT, peerID, nil
+	}
+
+	groupID, err := strconv.Atoi(group)
+	if err != nil || groupID <= 0 {
+		return 0, 0, errors.New("无效的群组ID")
+	}
+	return model.GROUP_CHAT, groupID, nil
+}
+
+// parseLimit 解析每页条数，未指定时使用默认值
+func parseLimit(limitStr string) (int, error) {
+	if limitStr == "" {
+		return defaultHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("无效的 limit 参数")
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	return limit, nil
+}
+
+// checkConversationAccess 检查用户是否是会话参与者：单聊要求是好友，群聊要求是群成员
+func checkConversationAccess(ctx context.Context, userID int, chatType model.ChatType, targetID int) error {
+	var ok bool
+	var err error
+	switch chatType {
+	case model.SINGLE_CHAT:
+		ok, err = IsFriends(ctx, userID, targetID)
+	case model.GROUP_CHAT:
+		ok, err = IsGroupMember(ctx, userID, targetID)
+	default:
+		return errors.New("未知的会话类型")
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNotParticipant
+	}
+	return nil
+}
+
+// conversationScope 构造指定会话的消息查询条件
+func conversationScope(chatType model.ChatType, userID, targetID int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if chatType == model.GROUP_CHAT {
+			return db.Where("chat_type = ? AND send_target = ?", model.GROUP_CHAT, strconv.Itoa(targetID))
+		}
+		me, peer := strconv.Itoa(userID), strconv.Itoa(targetID)
+		return db.Where("chat_type = ? AND ((user_from = ? AND send_target = ?) OR (user_from = ? AND send_target = ?))",
+			model.SINGLE_CHAT, me, peer, peer, me)
+	}
+}
+
+// encodeCursor 将消息主键编码为不透明游标
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeCursor 解析不透明游标
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
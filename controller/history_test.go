@@ -0,0 +1,42 @@
+package controller
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, id := range []uint{0, 1, 42, 1<<32 + 7} {
+		got, err := decodeCursor(encodeCursor(id))
+		if err != nil {
+			t.Fatalf("decodeCursor(encodeCursor(%d)) err = %v", id, err)
+		}
+		if got != id {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d", id, got)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    uint
+		wantErr bool
+	}{
+		{"有效游标", "MTIz", 123, false},
+		{"不是 base64", "!!", 0, true},
+		{"不是数字", "YWJj", 0, true},
+		{"负数", "LTE", 0, true},
+		{"带填充", "MTIz=", 0, true},
+		{"空字符串", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCursor(%q) err = %v, wantErr %v", tt.cursor, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decodeCursor(%q) = %d, want %d", tt.cursor, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"gorm.io/gorm/clause"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const mentionAllName = "all" // @all 提及全体成员
+
+// mentionPattern 匹配行首或空白后的 @用户名，避免把邮箱地址当作提及
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_\-.]+)`)
+
+var (
+	errMentionAllForbidden = errors.New("只有群主或管理员可以 @all")
+	errMentionNotMember    = errors.New("被提及的用户不是群成员")
+)
+
+// parseMentions 从消息内容中解析被提及的用户名，@all 单独返回
+func parseMentions(content string) ([]string, bool) {
+	var usernames []string
+	all := false
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if name == mentionAllName {
+			all = true
+			continue
+		}
+		usernames = append(usernames, name)
+	}
+	return usernames, all
+}
+
+// resolveMentions 将消息中的提及解析为用户ID，并检查提及的权限
+// 不存在的用户名按普通文本处理；存在但不在群中的用户会返回 errMentionNotMember
+func resolveMentions(ctx context.Context, senderID, groupID int, content string) ([]int, bool, error) {
+	usernames, all := parseMentions(content)
+	if all {
+		role, err := getGroupRole(ctx, senderID, groupID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !isGroupManager(role) {
+			return nil, false, errMentionAllForbidden
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, all, nil
+	}
+
+	var users []model.User
+	if err := database.GetDB().Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, false, err
+	}
+
+	userIDs := make([]int, 0, len(users))
+	for _, user := range users {
+		if int(user.ID) == senderID {
+			continue
+		}
+		isMember, err := IsGroupMember(ctx, int(user.ID), groupID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !isMember {
+			return nil, false, fmt.Errorf("%w: %s", errMentionNotMember, user.Username)
+		}
+		userIDs = append(userIDs, int(user.ID))
+	}
+	return userIDs, all, nil
+}
+
+// saveMentions 为被提及的用户写入提及收件箱，返回被提及的用户ID集合
+// @all 时提及除发送者外的所有成员
+func saveMentions(ctx context.Context, msg model.MyMessage, userIDs []int, all bool, members []model.GroupMember) (map[int]bool, error) {
+	senderID, _ := strconv.Atoi(msg.UserFrom)
+	groupID, _ := strconv.Atoi(msg.SendTarget)
+
+	mentioned := make(map[int]bool)
+	if all {
+		for _, member := range members {
+			if member.UserID != senderID {
+				mentioned[member.UserID] = true
+			}
+		}
+	}
+	for _, userID := range userIDs {
+		mentioned[userID] = true
+	}
+	if len(mentioned) == 0 {
+		return mentioned, nil
+	}
+
+	mentions := make([]model.MessageMention, 0, len(mentioned))
+	for userID := range mentioned {
+		mentions = append(mentions, model.MessageMention{
+			UserID:     userID,
+			MessageID:  msg.MessageID,
+			GroupID:    groupID,
+			SenderID:   senderID,
+			MentionAll: all,
+		})
+	}
+	err := database.GetDB().WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&mentions, 500).Error
+	return mentioned, err
+}
+
+// deliverMention 以高优先级投递提及了接收者的群消息，离线用户上线后最先收到
+func deliverMention(ctx context.Context, userIDStr string, msg model.MyMessage) error {
+	env, err := delivery.NewEnvelope(userIDStr, msg.MessageID, delivery.EventMention, msg)
+	if err != nil {
+		return err
+	}
+	env.Priority = true
+	return delivery.Deliver(ctx, env)
+}
+
+// MentionItem 提及收件箱中的一条记录
+type MentionItem struct {
+	MentionID  uint            `json:"mention_id"`
+	MentionAll bool            `json:"mention_all"`
+	Read       bool            `json:"read"`
+	Message    model.MyMessage `json:"message"`
+}
+
+// GetMentions 按时间倒序分页获取提及收件箱，unread=1 时只返回未读提及
+func GetMentions(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	limit, err := parseLimit(ctx.Query("limit"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	query := db.Where("user_id = ?", int(UserID))
+	if cursor := ctx.Query("before"); cursor != "" {
+		before, err := decodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的游标"})
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+	if ctx.Query("unread") == "1" {
+		query = query.Where("`read` = ?", false)
+	}
+
+	var mentions []model.MessageMention
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&mentions).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(mentions) > limit
+	if hasMore {
+		mentions = mentions[:limit]
+	}
+	nextCursor := ""
+	if hasMore {
+		nextCursor = encodeCursor(mentions[len(mentions)-1].ID)
+	}
+
+	messageIDs := make([]string, len(mentions))
+	for i, mention := range mentions {
+		messageIDs[i] = mention.MessageID
+	}
+	var messages []model.MyMessage
+	if len(messageIDs) > 0 {
+		if err := db.Where("message_id IN ?", messageIDs).Find(&messages).Error; err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	messageMap := make(map[string]model.MyMessage, len(messages))
+	for _, msg := range messages {
+		messageMap[msg.MessageID] = msg
+	}
+
+	items := make([]MentionItem, 0, len(mentions))
+	for _, mention := range mentions {
+		msg, ok := messageMap[mention.MessageID]
+		if !ok {
+			continue
+		}
+		items = append(items, MentionItem{
+			MentionID:  mention.ID,
+			MentionAll: mention.MentionAll,
+			Read:       mention.Read,
+			Message:    msg,
+		})
+	}
+
+	unread, err := countUnreadMentions(int(UserID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"mentions":    items,
+		"unread":      unread,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	})
+}
+
+// ReadMentions 标记提及已读，未指定提及ID时全部标记已读，请求体为空时同样视为全部标记已读
+func ReadMentions(ctx *gin.Context) {
+	var req request.MentionRead
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	query := database.GetDB().Model(&model.MessageMention{}).
+		Where("user_id = ? AND `read` = ?", int(UserID), false)
+	if len(req.MentionIDs) > 0 {
+		query = query.Where("id IN ?", req.MentionIDs)
+	}
+	if err := query.Update("read", true).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	unread, err := countUnreadMentions(int(UserID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "标记已读成功", "unread": unread})
+}
+
+// countUnreadMentions 统计用户未读的提及数
+func countUnreadMentions(userID int) (int64, error) {
+	var count int64
+	err := database.GetDB().Model(&model.MessageMention{}).
+		Where("user_id = ? AND `read` = ?", userID, false).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		usernames []string
+		all       bool
+	}{
+		{"单个提及", "@alice 你好", []string{"alice"}, false},
+		{"多个提及", "hi @alice and @bob", []string{"alice", "bob"}, false},
+		{"重复提及只保留一次", "@alice @alice", []string{"alice"}, false},
+		{"提及全体成员", "@all 开会了", nil, true},
+		{"全体成员和个人", "@all @bob", []string{"bob"}, true},
+		{"邮箱不是提及", "mail me at alice@example.com", nil, false},
+		{"去掉句末的点", "thanks @alice.", []string{"alice"}, false},
+		{"中文用户名", "@张三 在吗", []string{"张三"}, false},
+		{"换行后的提及", "第一行\n@bob", []string{"bob"}, false},
+		{"单独的 @", "@ 没有名字", nil, false},
+		{"没有提及", "hello", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usernames, all := parseMentions(tt.content)
+			if !reflect.DeepEqual(usernames, tt.usernames) || all != tt.all {
+				t.Errorf("parseMentions(%q) = %q, %v, want %q, %v", tt.content, usernames, all, tt.usernames, tt.all)
+			}
+		})
+	}
+}
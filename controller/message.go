@@ -0,0 +1,375 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/helpleness/IMChatAdmin/service/websocket"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusError 带 HTTP 状态码的错误，HTTP 接口和 WebSocket 命令共用同一套处理逻辑时使用
+type statusError struct {
+	Status int
+	Err    error
+}
+
+func (e *statusError) Error() string { return e.Err.Error() }
+
+func (e *statusError) Unwrap() error { return e.Err }
+
+// withStatus 为错误附加 HTTP 状态码
+func withStatus(status int, err error) error {
+	return &statusError{Status: status, Err: err}
+}
+
+// errorStatus 取出错误对应的 HTTP 状态码，未附加时按服务端错误处理
+func errorStatus(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// SendMessage 发送单聊消息，持久化后投递到对方所在节点或离线队列
+func SendMessage(ctx *gin.Context) {
+	var req request.MessageSend
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	result, err := postMessage(ctx, UserID, model.SINGLE_CHAT, req)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// SendGroupMessage 发送群聊消息，消息只落库一次，再扇出给每个群成员
+func SendGroupMessage(ctx *gin.Context) {
+	var req request.MessageSend
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	result, err := postMessage(ctx, UserID, model.GROUP_CHAT, req)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// outgoingMessage 校验通过、等待保存和投递的消息及其提及的成员
+type outgoingMessage struct {
+	msg        model.MyMessage
+	mentionIDs []int
+	mentionAll bool
+}
+
+// postMessage 校验并发送一条单聊或群聊消息，返回给客户端的结果
+// 指定了未来的 send_at 时只保存定时消息
+func postMessage(ctx context.Context, UserID uint, chatType model.ChatType, req request.MessageSend) (gin.H, error) {
+	out, err := prepareMessage(ctx, UserID, chatType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 指定了未来的发送时间时只保存定时消息，到期后由定时任务发送
+	if req.SendAt > time.Now().Unix() {
+		scheduled, err := scheduleMessage(out.msg, req.SendAt)
+		if err != nil {
+			return nil, withStatus(http.StatusBadRequest, err)
+		}
+		return gin.H{
+			"message":     "定时消息已创建",
+			"schedule_id": scheduled.ID,
+			"message_id":  scheduled.MessageID,
+			"send_at":     scheduled.SendAt,
+		}, nil
+	}
+	return sendPrepared(ctx, out)
+}
+
+// prepareMessage 校验发送者权限和消息内容，解析提及并构造待发送的消息
+// 即时发送和到期的定时消息都经过这里，定时消息到期时按发送时的状态重新校验
+func prepareMessage(ctx context.Context, UserID uint, chatType model.ChatType, req request.MessageSend) (outgoingMessage, error) {
+	var out outgoingMessage
+	if chatType == model.SINGLE_CHAT && req.SendTarget == int(UserID) {
+		return out, withStatus(http.StatusBadRequest, errors.New("不能给自己发送消息"))
+	}
+	if err := validateMessageSend(&req); err != nil {
+		return out, withStatus(http.StatusBadRequest, err)
+	}
+	content, err := normalizePayload(ctx, UserID, req.Type, req.Content)
+	if err != nil {
+		return out, withStatus(http.StatusBadRequest, err)
+	}
+
+	switch chatType {
+	case model.SINGLE_CHAT:
+		// 只允许给好友发送消息
+		isfriends, err := IsFriends(ctx, int(UserID), req.SendTarget)
+		if err != nil {
+			return out, fmt.Errorf("检查好友关系失败: %w", err)
+		}
+		if !isfriends {
+			return out, withStatus(http.StatusForbidden, errors.New("对方不是你的好友"))
+		}
+	case model.GROUP_CHAT:
+		// 只允许群成员发送群消息
+		isMember, err := IsGroupMember(ctx, int(UserID), req.SendTarget)
+		if err != nil {
+			return out, fmt.Errorf("检查群成员身份失败: %w", err)
+		}
+		if !isMember {
+			return out, withStatus(http.StatusForbidden, errors.New("你不是该群成员"))
+		}
+	default:
+		return out, withStatus(http.StatusBadRequest, errors.New("未知的会话类型"))
+	}
+
+	convID := conversationID(chatType, int(UserID), req.SendTarget)
+	if err := validateReplyRefs(database.GetDB(), convID, req.ReplyTo, req.ThreadRoot); err != nil {
+		return out, withStatus(http.StatusBadRequest, err)
+	}
+
+	// 只有群聊的文本消息支持 @ 提及
+	var mentionIDs []int
+	mentionAll := false
+	if chatType == model.GROUP_CHAT && req.Type == model.TEXT {
+		mentionIDs, mentionAll, err = resolveMentions(ctx, int(UserID), req.SendTarget, content)
+		if err != nil {
+			switch {
+			case errors.Is(err, errMentionAllForbidden):
+				return out, withStatus(http.StatusForbidden, err)
+			case errors.Is(err, errMentionNotMember):
+				return out, withStatus(http.StatusBadRequest, err)
+			}
+			return out, err
+		}
+	}
+
+	// 由服务端分配消息ID和发送时间
+	out.msg = model.MyMessage{
+		MessageID:  uuid.NewString(),
+		UserFrom:   strconv.Itoa(int(UserID)),
+		SendTarget: strconv.Itoa(req.SendTarget),
+		Content:    content,
+		Type:       req.Type,
+		ChatType:   chatType,
+		SendTime:   time.Now().Unix(),
+		ReplyTo:    req.ReplyTo,
+		ThreadRoot: req.ThreadRoot,
+	}
+	out.mentionIDs = mentionIDs
+	out.mentionAll = mentionAll
+	return out, nil
+}
+
+// sendPrepared 保存消息并投递给接收者或群成员，群消息同时保存提及记录
+func sendPrepared(ctx context.Context, out outgoingMessage) (gin.H, error) {
+	msg := out.msg
+	if err := saveMessage(ctx, &msg); err != nil {
+		return nil, err
+	}
+
+	result := gin.H{
+		"message":    "消息发送成功",
+		"message_id": msg.MessageID,
+		"conv":       msg.ConvID,
+		"seq":        msg.Seq,
+		"send_time":  msg.SendTime,
+	}
+
+	if msg.ChatType == model.SINGLE_CHAT {
+		if err := deliverMessage(ctx, msg.SendTarget, msg); err != nil {
+			// 消息已经落库，投递失败只记录日志
+			log.Printf("投递消息 %s 到用户 %s 失败: %v", msg.MessageID, msg.SendTarget, err)
+		}
+		return result, nil
+	}
+
+	redisCli := database.GetRedisClient()
+	groupID, _ := strconv.Atoi(msg.SendTarget)
+	members, err := loadGroupMembers(ctx, redisCli, groupID)
+	if err != nil {
+		// 消息已经落库，成员获取失败只记录日志
+		log.Printf("获取群 %d 成员失败，消息 %s 未扇出: %v", groupID, msg.MessageID, err)
+	}
+	mentioned, err := saveMentions(ctx, msg, out.mentionIDs, out.mentionAll, members)
+	if err != nil {
+		log.Printf("保存消息 %s 的提及记录失败: %v", msg.MessageID, err)
+	}
+	result["delivered"] = fanoutGroupMessage(ctx, members, msg, mentioned)
+	result["mentioned"] = len(mentioned)
+	return result, nil
+}
+
+// validateMessageSend 验证发送消息的参数
+func validateMessageSend(req *request.MessageSend) error {
+	if req.SendTarget <= 0 {
+		return errors.New("接收者ID不正确")
+	}
+	if !isChatMessageType(req.Type) {
+		return errors.New("不支持的消息类型")
+	}
+	if req.Content == "" {
+		return errors.New("消息内容不能为空")
+	}
+	return nil
+}
+
+// loadGroupMembers 获取群成员列表，优先读缓存，未命中时查询数据库并回填缓存
+func loadGroupMembers(ctx context.Context, redisCli *redis.Client, groupID int) ([]model.GroupMember, error) {
+	members, err := getGroupMembersFromCache(ctx, redisCli, groupID)
+	if err == nil && len(members) > 0 {
+		return members, nil
+	}
+
+	members, err = getGroupMembersFromDB(ctx, database.GetDB(), groupID)
+	if err != nil {
+		return nil, err
+	}
+	go cacheGroupMembers(context.Background(), redisCli, groupID, members)
+	return members, nil
+}
+
+// fanoutGroupMessage 将群消息投递给除发送者外的每个成员，被提及的成员以高优先级投递，返回成功投递的人数
+func fanoutGroupMessage(ctx context.Context, members []model.GroupMember, msg model.MyMessage, mentioned map[int]bool) int {
+	delivered := 0
+	for _, member := range members {
+		memberIDStr := strconv.Itoa(member.UserID)
+		if memberIDStr == msg.UserFrom {
+			continue
+		}
+		var err error
+		if mentioned[member.UserID] {
+			err = deliverMention(ctx, memberIDStr, msg)
+		} else {
+			err = deliverMessage(ctx, memberIDStr, msg)
+		}
+		if err != nil {
+			log.Printf("投递群消息 %s 到用户 %s 失败: %v", msg.MessageID, memberIDStr, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// conversationParticipants 获取消息所在会话的全部参与者ID
+func conversationParticipants(ctx context.Context, msg model.MyMessage) ([]string, error) {
+	if msg.ChatType == model.SINGLE_CHAT {
+		return []string{msg.UserFrom, msg.SendTarget}, nil
+	}
+
+	groupID, err := strconv.Atoi(msg.SendTarget)
+	if err != nil {
+		return nil, err
+	}
+	members, err := loadGroupMembers(ctx, database.GetRedisClient(), groupID)
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]string, len(members))
+	for i, member := range members {
+		participants[i] = strconv.Itoa(member.UserID)
+	}
+	return participants, nil
+}
+
+// broadcastEvent 向一组用户投递同一个系统事件，返回成功投递的人数
+func broadcastEvent(ctx context.Context, userIDs []string, event string, data interface{}) int {
+	eventID := uuid.NewString()
+	delivered := 0
+	for _, userID := range userIDs {
+		env, err := delivery.NewEnvelope(userID, eventID, event, data)
+		if err == nil {
+			err = delivery.Deliver(ctx, env)
+		}
+		if err != nil {
+			log.Printf("投递 %s 事件到用户 %s 失败: %v", event, userID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// notifyOnline 向一组用户中在线的用户推送事件，不保存离线副本
+// 同一节点上的用户通过一次 gRPC 调用推送
+func notifyOnline(ctx context.Context, userIDs []string, event string, data interface{}) {
+	env, err := delivery.NewEnvelope("", uuid.NewString(), event, data)
+	if err != nil {
+		log.Printf("构造 %s 事件失败: %v", event, err)
+		return
+	}
+	delivery.NotifyGroup(ctx, 0, userIDs, env)
+}
+
+// isChatMessageType 判断是否为用户可以直接发送的聊天消息类型
+func isChatMessageType(t model.MessageType) bool {
+	switch t {
+	case model.TEXT, model.IMAGE, model.FILE, model.VOICE, model.LOCATION:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliverMessage 将消息投递给指定用户
+// 消息先进入用户的待确认队列，用户在线时同时推送到其所在节点的消息流 message_stream<ip>
+func deliverMessage(ctx context.Context, userIDStr string, msg model.MyMessage) error {
+	env, err := delivery.NewEnvelope(userIDStr, msg.MessageID, delivery.EventMessage, msg)
+	if err != nil {
+		return err
+	}
+	return delivery.Deliver(ctx, env)
+}
+
+// AckMessages 客户端确认已收到消息，确认后的消息不再向该设备重新投递
+func AckMessages(ctx *gin.Context) {
+	var req request.MessageAck
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.MessageIDs) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息ID不能为空"})
+		return
+	}
+	// 与 WebSocket 连接相同，通过 appId 查询参数指定确认的设备，未指定时为默认平台
+	appID, err := websocket.ParseAppID(ctx.Query("appId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	acked, err := delivery.Ack(ctx, strconv.Itoa(int(UserID)), appID, req.MessageIDs...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "确认成功", "acked": acked})
+}
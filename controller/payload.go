@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/viper"
+	"regexp"
+)
+
+const maxVoiceDuration = 60 * 10 // 语音消息最长时长（秒）
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// normalizePayload 校验消息内容并返回规范化后的内容
+// 文本消息原样返回；其他类型必须是对应结构的 JSON，引用的对象必须存在且由发送者上传
+func normalizePayload(ctx context.Context, userID uint, msgType model.MessageType, content string) (string, error) {
+	if msgType == model.TEXT {
+		return content, nil
+	}
+	payload, objectKeys, err := validatePayload(msgType, content)
+	if err != nil {
+		return "", err
+	}
+	for _, objectKey := range objectKeys {
+		size := int64(-1)
+		if p, ok := payload.(model.FilePayload); ok && objectKey == p.ObjectKey {
+			size = p.Size
+		}
+		if err := checkUploadedObject(ctx, userID, objectKey, size); err != nil {
+			return "", err
+		}
+	}
+
+	normalized, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+// validatePayload 解析并校验非文本消息的内容，返回解析后的结构和其中引用的对象名
+// 只检查内容本身，对象是否存在由 checkUploadedObject 检查
+func validatePayload(msgType model.MessageType, content string) (interface{}, []string, error) {
+	switch msgType {
+	case model.IMAGE:
+		var p model.ImagePayload
+		if err := decodePayload(content, &p); err != nil {
+			return nil, nil, err
+		}
+		if p.Width <= 0 || p.Height <= 0 {
+			return nil, nil, errors.New("图片宽高不正确")
+		}
+		keys := []string{p.ObjectKey}
+		if p.ThumbnailKey != "" {
+			keys = append(keys, p.ThumbnailKey)
+		}
+		return p, keys, nil
+	case model.FILE:
+		var p model.FilePayload
+		if err := decodePayload(content, &p); err != nil {
+			return nil, nil, err
+		}
+		if p.Size <= 0 {
+			return nil, nil, errors.New("文件大小不正确")
+		}
+		if !sha256Pattern.MatchString(p.SHA256) {
+			return nil, nil, errors.New("文件摘要不正确")
+		}
+		if p.Mime == "" {
+			return nil, nil, errors.New("文件类型不能为空")
+		}
+		return p, []string{p.ObjectKey}, nil
+	case model.VOICE:
+		var p model.VoicePayload
+		if err := decodePayload(content, &p); err != nil {
+			return nil, nil, err
+		}
+		if p.Duration <= 0 || p.Duration > maxVoiceDuration {
+			return nil, nil, errors.New("语音时长不正确")
+		}
+		return p, []string{p.ObjectKey}, nil
+	case model.LOCATION:
+		var p model.LocationPayload
+		if err := decodePayload(content, &p); err != nil {
+			return nil, nil, err
+		}
+		if p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+			return nil, nil, errors.New("经纬度超出范围")
+		}
+		return p, nil, nil
+	default:
+		return nil, nil, errors.New("不支持的消息类型")
+	}
+}
+
+// decodePayload 严格解析消息内容，不允许出现未知字段
+func decodePayload(content string, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("消息内容格式不正确: %v", err)
+	}
+	return nil
+}
+
+// checkUploadedObject 检查对象是否由该用户上传且仍存在于 MinIO 中，size 为 -1 时不校验大小
+func checkUploadedObject(ctx context.Context, userID uint, objectKey string, size int64) error {
+	if objectKey == "" {
+		return errors.New("对象名不能为空")
+	}
+	bucketName := viper.GetString("minio.bucket")
+
+	var count int64
+	if err := database.GetDB().Table("files").
+		Where("name = ? AND bucket = ? AND user_id = ?", objectKey, bucketName, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("对象 %s 不是你上传的文件", objectKey)
+	}
+
+	info, err := database.GetMinioClisnt().StatObject(ctx, bucketName, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("对象 %s 不存在: %v", objectKey, err)
+	}
+	if size >= 0 && info.Size != size {
+		return fmt.Errorf("对象 %s 大小不一致", objectKey)
+	}
+	return nil
+}
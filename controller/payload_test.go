@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helpleness/IMChatAdmin/model"
+)
+
+func TestValidatePayload(t *testing.T) {
+	sha := strings.Repeat("a", 64)
+	tests := []struct {
+		name    string
+		msgType model.MessageType
+		content string
+		keys    []string
+		wantErr bool
+	}{
+		{"图片", model.IMAGE, `{"object_key":"a.png","width":10,"height":20}`, []string{"a.png"}, false},
+		{"图片带缩略图", model.IMAGE, `{"object_key":"a.png","width":10,"height":20,"thumbnail_key":"a_s.png"}`, []string{"a.png", "a_s.png"}, false},
+		{"图片宽高为0", model.IMAGE, `{"object_key":"a.png","width":0,"height":20}`, nil, true},
+		{"未知字段", model.IMAGE, `{"object_key":"a.png","width":10,"height":20,"url":"x"}`, nil, true},
+		{"不是JSON", model.IMAGE, `a.png`, nil, true},
+		{"文件", model.FILE, `{"object_key":"a.zip","size":100,"sha256":"` + sha + `","mime":"application/zip"}`, []string{"a.zip"}, false},
+		{"文件大小为0", model.FILE, `{"object_key":"a.zip","size":0,"sha256":"` + sha + `","mime":"application/zip"}`, nil, true},
+		{"文件摘要错误", model.FILE, `{"object_key":"a.zip","size":100,"sha256":"abc","mime":"application/zip"}`, nil, true},
+		{"文件类型为空", model.FILE, `{"object_key":"a.zip","size":100,"sha256":"` + sha + `"}`, nil, true},
+		{"语音", model.VOICE, `{"object_key":"a.amr","duration":30}`, []string{"a.amr"}, false},
+		{"语音过长", model.VOICE, `{"object_key":"a.amr","duration":601}`, nil, true},
+		{"语音时长为0", model.VOICE, `{"object_key":"a.amr","duration":0}`, nil, true},
+		{"位置", model.LOCATION, `{"lat":31.2,"lng":121.5,"label":"上海"}`, nil, false},
+		{"纬度越界", model.LOCATION, `{"lat":91,"lng":121.5}`, nil, true},
+		{"经度越界", model.LOCATION, `{"lat":31.2,"lng":-181}`, nil, true},
+		{"不支持的类型", model.MERGED_FORWARD, `{}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, keys, err := validatePayload(tt.msgType, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePayload() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("validatePayload() keys = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxPinnedMessages = 20 // 每个群最多置顶的消息数
+
+// pinCacheKey 群置顶消息列表的缓存
+func pinCacheKey(groupID int) string {
+	return "group_pins:" + strconv.Itoa(groupID)
+}
+
+// PinMessage 群主或管理员置顶群消息
+func PinMessage(ctx *gin.Context) {
+	var req request.PinMessage
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groupID, UserID, ok := checkPinPermission(ctx)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	msg, err := findMessage(db, req.MessageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.ConvID != conversationID(model.GROUP_CHAT, UserID, groupID) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息不属于该群"})
+		return
+	}
+	if msg.Recalled {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息已撤回"})
+		return
+	}
+
+	var count int64
+	if err := db.Model(&model.PinnedMessage{}).Where("group_id = ?", groupID).Count(&count).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count >= maxPinnedMessages {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "置顶消息数量已达上限"})
+		return
+	}
+
+	pin := model.PinnedMessage{GroupID: groupID, MessageID: msg.MessageID, PinnedBy: UserID}
+	if err := db.Where("group_id = ? AND message_id = ?", groupID, msg.MessageID).FirstOrCreate(&pin).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	afterPinChanged(ctx, groupID, gin.H{
+		"group_id":   groupID,
+		"message_id": msg.MessageID,
+		"pinned":     true,
+		"by":         UserID,
+	})
+	ctx.JSON(http.StatusOK, gin.H{"message": "置顶成功", "pin": pin})
+}
+
+// UnpinMessage 群主或管理员取消置顶群消息
+func UnpinMessage(ctx *gin.Context) {
+	groupID, UserID, ok := checkPinPermission(ctx)
+	if !ok {
+		return
+	}
+	messageID := ctx.Param("messageId")
+
+	result := database.GetDB().Where("group_id = ? AND message_id = ?", groupID, messageID).Delete(&model.PinnedMessage{})
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "该消息未被置顶"})
+		return
+	}
+
+	afterPinChanged(ctx, groupID, gin.H{
+		"group_id":   groupID,
+		"message_id": messageID,
+		"pinned":     false,
+		"by":         UserID,
+	})
+	ctx.JSON(http.StatusOK, gin.H{"message": "取消置顶成功"})
+}
+
+// GetPinnedMessages 获取群置顶消息列表，置顶记录优先读缓存
+func GetPinnedMessages(ctx *gin.Context) {
+	groupID, err := parseGroupParam(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	if err := checkConversationAccess(ctx, int(UserID), model.GROUP_CHAT, groupID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	pins, err := loadPinnedMessages(ctx, groupID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 消息内容可能被撤回或编辑，每次从数据库读取
+	messageIDs := make([]string, len(pins))
+	for i, pin := range pins {
+		messageIDs[i] = pin.MessageID
+	}
+	var messages []model.MyMessage
+	if len(messageIDs) > 0 {
+		if err := database.GetDB().Where("message_id IN ?", messageIDs).Find(&messages).Error; err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	messageMap := make(map[string]model.MyMessage, len(messages))
+	for _, msg := range messages {
+		messageMap[msg.MessageID] = msg
+	}
+
+	items := make([]gin.H, 0, len(pins))
+	for _, pin := range pins {
+		msg, ok := messageMap[pin.MessageID]
+		if !ok {
+			continue
+		}
+		items = append(items, gin.H{
+			"message":   msg,
+			"pinned_by": pin.PinnedBy,
+			"pinned_at": pin.CreatedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"group_id": groupID, "pins": items})
+}
+
+// checkPinPermission 解析群组ID并检查调用者是群主或管理员，失败时已写入响应
+func checkPinPermission(ctx *gin.Context) (int, int, bool) {
+	groupID, err := parseGroupParam(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	role, err := getGroupRole(ctx, int(UserID), groupID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	if !isGroupManager(role) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只有群主或管理员可以置顶消息"})
+		return 0, 0, false
+	}
+	return groupID, int(UserID), true
+}
+
+// afterPinChanged 置顶变化后清理缓存并通知在线成员
+func afterPinChanged(ctx *gin.Context, groupID int, event gin.H) {
+	redisCli := database.GetRedisClient()
+	if err := redisCli.Del(ctx, pinCacheKey(groupID)).Err(); err != nil {
+		log.Printf("删除群 %d 置顶缓存失败: %v", groupID, err)
+	}
+
+	members, err := loadGroupMembers(ctx, redisCli, groupID)
+	if err != nil {
+		log.Printf("获取群 %d 成员失败，置顶变化未推送: %v", groupID, err)
+		return
+	}
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = strconv.Itoa(member.UserID)
+	}
+	notifyOnline(ctx, userIDs, delivery.EventPin, event)
+}
+
+// loadPinnedMessages 获取群置顶记录，缓存未命中时查询数据库并回填缓存
+func loadPinnedMessages(ctx *gin.Context, groupID int) ([]model.PinnedMessage, error) {
+	redisCli := database.GetRedisClient()
+	cacheKey := pinCacheKey(groupID)
+
+	var pins []model.PinnedMessage
+	pinCache, err := redisCli.Get(ctx, cacheKey).Result()
+	if err == nil {
+		if err := json.Unmarshal([]byte(pinCache), &pins); err != nil {
+			log.Printf("解析群 %d 置顶缓存错误: %v", groupID, err)
+		} else {
+			return pins, nil
+		}
+	}
+
+	if err := database.GetDB().Where("group_id = ?", groupID).Order("id DESC").Find(&pins).Error; err != nil {
+		return nil, err
+	}
+
+	pinCacheMarshal, _ := json.Marshal(pins)
+	if err := redisCli.Set(ctx, cacheKey, pinCacheMarshal, 7*24*time.Hour).Err(); err != nil {
+		log.Printf("缓存群 %d 置顶消息错误: %v", groupID, err)
+	}
+	return pins, nil
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/model"
+)
+
+// newTestContext 构造调用者为 userID、路径参数 id 为 groupID 的请求上下文
+func newTestContext(userID uint, groupID string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx.Params = gin.Params{{Key: "id", Value: groupID}}
+	ctx.Set("userid", userID)
+	return ctx, w
+}
+
+// seedGroupMembers 写入群成员缓存，避免查询数据库
+func seedGroupMembers(t *testing.T, mr *miniredis.Miniredis, groupID string, members ...model.GroupMember) {
+	t.Helper()
+	for _, member := range members {
+		data, err := json.Marshal(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mr.Lpush("group_member:"+groupID, string(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckPinPermission(t *testing.T) {
+	mr := setupRedis(t)
+	seedGroupMembers(t, mr, "1",
+		model.GroupMember{GroupID: 1, UserID: 1, Role: "owner"},
+		model.GroupMember{GroupID: 1, UserID: 2, Role: "admin"},
+		model.GroupMember{GroupID: 1, UserID: 3, Role: "member"},
+	)
+
+	tests := []struct {
+		name    string
+		userID  uint
+		groupID string
+		ok      bool
+		code    int
+	}{
+		{"群主", 1, "1", true, http.StatusOK},
+		{"管理员", 2, "1", true, http.StatusOK},
+		{"普通成员", 3, "1", false, http.StatusForbidden},
+		{"非成员", 4, "1", false, http.StatusForbidden},
+		{"无效的群组ID", 1, "abc", false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, w := newTestContext(tt.userID, tt.groupID)
+			groupID, userID, ok := checkPinPermission(ctx)
+			if ok != tt.ok || w.Code != tt.code {
+				t.Fatalf("checkPinPermission = %v, 状态码 %d, want %v, %d", ok, w.Code, tt.ok, tt.code)
+			}
+			if ok && (groupID != 1 || userID != int(tt.userID)) {
+				t.Errorf("checkPinPermission = %d, %d, want 1, %d", groupID, userID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestLoadPinnedMessagesUsesCache(t *testing.T) {
+	mr := setupRedis(t)
+	cached := []model.PinnedMessage{{ID: 2, GroupID: 1, MessageID: "m2", PinnedBy: 1}, {ID: 1, GroupID: 1, MessageID: "m1", PinnedBy: 2}}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Set(pinCacheKey(1), string(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存命中时不查询数据库（测试中没有数据库连接）
+	ctx, _ := newTestContext(1, "1")
+	pins, err := loadPinnedMessages(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || pins[0].MessageID != "m2" || pins[1].MessageID != "m1" {
+		t.Errorf("loadPinnedMessages = %+v, want 缓存中的 m2、m1", pins)
+	}
+}
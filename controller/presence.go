@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/service/presence"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxPresenceIDs = 200 // 单次最多查询的用户数
+
+// GetPresence 批量查询用户的在线状态和最后在线时间，ids 为逗号分隔的用户ID
+func GetPresence(ctx *gin.Context) {
+	idsParam := strings.TrimSpace(ctx.Query("ids"))
+	if idsParam == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "用户ID不能为空"})
+		return
+	}
+
+	parts := strings.Split(idsParam, ",")
+	if len(parts) > maxPresenceIDs {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "一次最多查询 " + strconv.Itoa(maxPresenceIDs) + " 个用户"})
+		return
+	}
+	userIDs := make([]uint, 0, len(parts))
+	seen := make(map[uint]bool, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil || id == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID: " + part})
+			return
+		}
+		if seen[uint(id)] {
+			continue
+		}
+		seen[uint(id)] = true
+		userIDs = append(userIDs, uint(id))
+	}
+
+	presences, err := presence.Get(ctx, userIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"presence": presences})
+}
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxEmojiRunes    = 8                  // 单个表情允许的最大字符数（组合表情由多个码点组成）
+	reactionCacheTTL = 7 * 24 * time.Hour // 表情计数缓存的过期时间
+
+	// reactionCacheMarker 缓存哈希表中的占位字段，用来区分"没有回应"和"缓存未命中"
+	reactionCacheMarker = "_"
+)
+
+// reactionKey 消息表情回应计数的哈希表
+func reactionKey(messageID string) string {
+	return "reactions:" + messageID
+}
+
+// reactionVersionKey 消息表情回应的版本号，每次回应变化都会递增
+// 从数据库回填缓存前先读取版本号，回填时版本号已经变化说明读到的计数可能已经过时，放弃回填
+func reactionVersionKey(messageID string) string {
+	return "reactions_ver:" + messageID
+}
+
+// AddReaction 对消息添加表情回应
+func AddReaction(ctx *gin.Context) {
+	var req request.MessageReaction
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	handleReaction(ctx, req.Emoji, 1)
+}
+
+// RemoveReaction 取消对消息的表情回应
+func RemoveReaction(ctx *gin.Context) {
+	handleReaction(ctx, ctx.Query("emoji"), -1)
+}
+
+// handleReaction 添加或取消表情回应，变化后推送给在线的会话参与者
+func handleReaction(ctx *gin.Context, emoji string, delta int64) {
+	if emoji == "" || len(emoji) > 32 || utf8.RuneCountInString(emoji) > maxEmojiRunes {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的表情"})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	db := database.GetDB()
+	msg, err := findMessage(db, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.Recalled {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息已撤回"})
+		return
+	}
+	if err := checkMessageAccess(ctx, int(UserID), msg); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result *gorm.DB
+	if delta > 0 {
+		reaction := model.MessageReaction{MessageID: msg.MessageID, UserID: int(UserID), Emoji: emoji}
+		result = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	} else {
+		result = db.Where("message_id = ? AND user_id = ? AND emoji = ?", msg.MessageID, int(UserID), emoji).
+			Delete(&model.MessageReaction{})
+	}
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	// 重复添加或取消不存在的回应时计数不变
+	changed := result.RowsAffected > 0
+	if changed {
+		if err := adjustReactionCount(ctx, msg.MessageID, emoji, delta); err != nil {
+			log.Printf("更新消息 %s 表情计数失败: %v", msg.MessageID, err)
+		}
+	}
+
+	counts, err := getReactionCounts(ctx, []string{msg.MessageID})
+	if err != nil {
+		log.Printf("获取消息 %s 表情计数失败: %v", msg.MessageID, err)
+	}
+
+	if changed {
+		participants, err := conversationParticipants(ctx, msg)
+		if err != nil {
+			log.Printf("获取消息 %s 的会话参与者失败: %v", msg.MessageID, err)
+		}
+		notifyOnline(ctx, participants, delivery.EventReact, gin.H{
+			"message_id": msg.MessageID,
+			"conv_id":    msg.ConvID,
+			"user_id":    int(UserID),
+			"emoji":      emoji,
+			"delta":      delta,
+			"count":      counts[msg.MessageID][emoji],
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message_id": msg.MessageID, "reactions": counts[msg.MessageID]})
+}
+
+// adjustReactionScript 递增回应版本号，缓存存在时增减表情计数，计数减到0时删除该字段；缓存不存在时返回0
+// KEYS: 计数哈希表、版本号；ARGV: 表情、增减值、过期秒数
+var adjustReactionScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local count = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+if count <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// adjustReactionCount 在数据库写入成功后增减缓存中的表情计数，缓存不存在时从数据库重建
+func adjustReactionCount(ctx context.Context, messageID, emoji string, delta int64) error {
+	redisCli := database.GetRedisClient()
+	adjusted, err := adjustReactionScript.Run(ctx, redisCli,
+		[]string{reactionKey(messageID), reactionVersionKey(messageID)},
+		emoji, delta, int64(reactionCacheTTL/time.Second)).Int()
+	if err != nil {
+		return err
+	}
+	if adjusted == 0 {
+		_, err := loadReactionCounts(ctx, []string{messageID})
+		return err
+	}
+	return nil
+}
+
+// getReactionCounts 批量获取消息的表情计数，缓存未命中的从数据库聚合并回填
+func getReactionCounts(ctx context.Context, messageIDs []string) (map[string]map[string]int64, error) {
+	result := make(map[string]map[string]int64, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	redisCli := database.GetRedisClient()
+
+	pipe := redisCli.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(messageIDs))
+	for i, messageID := range messageIDs {
+		cmds[i] = pipe.HGetAll(ctx, reactionKey(messageID))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if len(values) == 0 {
+			missing = append(missing, messageIDs[i])
+			continue
+		}
+		counts := make(map[string]int64)
+		for emoji, countStr := range values {
+			if emoji == reactionCacheMarker {
+				continue
+			}
+			if count, _ := strconv.ParseInt(countStr, 10, 64); count > 0 {
+				counts[emoji] = count
+			}
+		}
+		if len(counts) > 0 {
+			result[messageIDs[i]] = counts
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := loadReactionCounts(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for messageID, counts := range loaded {
+			result[messageID] = counts
+		}
+	}
+	return result, nil
+}
+
+// refillReactionScript 版本号与读取数据库之前相同时才用数据库中的计数重建缓存
+// KEYS: 计数哈希表、版本号；ARGV: 读取数据库前的版本号、过期秒数、表情、计数、表情、计数...
+var refillReactionScript = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '') ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], '` + reactionCacheMarker + `', 0)
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// loadReactionCounts 从数据库聚合表情计数并写入缓存
+// 读取数据库前记下版本号，期间有回应变化时不回填，避免并发的增减被过时的计数覆盖，下次读取时再重建
+func loadReactionCounts(ctx context.Context, messageIDs []string) (map[string]map[string]int64, error) {
+	redisCli := database.GetRedisClient()
+	versionKeys := make([]string, len(messageIDs))
+	for i, messageID := range messageIDs {
+		versionKeys[i] = reactionVersionKey(messageID)
+	}
+	versions, err := redisCli.MGet(ctx, versionKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	type reactionCount struct {
+		MessageID string
+		Emoji     string
+		Count     int64
+	}
+	var rows []reactionCount
+	if err := database.GetDB().Model(&model.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count").
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]int64)
+	for _, row := range rows {
+		if result[row.MessageID] == nil {
+			result[row.MessageID] = make(map[string]int64)
+		}
+		result[row.MessageID][row.Emoji] = row.Count
+	}
+
+	// 回填缓存，没有回应的消息也写入占位字段，避免反复查询数据库
+	pipe := redisCli.Pipeline()
+	for i, messageID := range messageIDs {
+		version, _ := versions[i].(string)
+		args := []interface{}{version, int64(reactionCacheTTL / time.Second)}
+		for emoji, count := range result[messageID] {
+			args = append(args, emoji, count)
+		}
+		refillReactionScript.Eval(ctx, pipe, []string{reactionKey(messageID), versionKeys[i]}, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("缓存表情计数错误: %v", err)
+	}
+	return result, nil
+}
+
+// messageIDsOf 提取消息列表的消息ID
+func messageIDsOf(messages []model.MyMessage) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.MessageID
+	}
+	return ids
+}
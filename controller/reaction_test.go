@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupRedis 用 miniredis 替换全局 Redis 客户端
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.RedisClient.Close()
+		database.RedisClient = nil
+	})
+	return mr
+}
+
+func TestReactionRefillSkipsConcurrentChange(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	redisCli := database.GetRedisClient()
+	keys := []string{reactionKey("m1"), reactionVersionKey("m1")}
+
+	// 读取数据库前缓存不存在，版本号为空
+	version := ""
+	// 读取数据库之后、回填之前有一次回应变化
+	adjusted, err := adjustReactionScript.Run(ctx, redisCli, keys, "👍", 1, 60).Int()
+	if err != nil || adjusted != 0 {
+		t.Fatalf("adjustReactionScript = %d, %v, want 0（缓存不存在）", adjusted, err)
+	}
+	refilled, err := refillReactionScript.Run(ctx, redisCli, keys, version, 60, "👍", 0).Int()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refilled != 0 || mr.Exists(reactionKey("m1")) {
+		t.Fatal("版本号变化后不应使用过时的计数回填缓存")
+	}
+
+	// 版本号未变化时正常回填，之后的增减落在缓存上
+	version, _ = mr.Get(reactionVersionKey("m1"))
+	refilled, err = refillReactionScript.Run(ctx, redisCli, keys, version, 60, "👍", 1).Int()
+	if err != nil || refilled != 1 {
+		t.Fatalf("refillReactionScript = %d, %v, want 1", refilled, err)
+	}
+	if _, err := adjustReactionScript.Run(ctx, redisCli, keys, "👍", 1, 60).Int(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.HGet(reactionKey("m1"), "👍"); got != "2" {
+		t.Errorf("缓存计数 = %s, want 2", got)
+	}
+	if _, err := adjustReactionScript.Run(ctx, redisCli, keys, "👍", -2, 60).Int(); err != nil {
+		t.Fatal(err)
+	}
+	if got := mr.HGet(reactionKey("m1"), "👍"); got != "" {
+		t.Errorf("计数减到0后应当删除字段，got %s", got)
+	}
+	if got := mr.HGet(reactionKey("m1"), reactionCacheMarker); got != "0" {
+		t.Errorf("占位字段 = %q, want 0", got)
+	}
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// advanceReadSeqScript 只在新的已读位置更大时才更新，避免并发请求把已读位置回退
+var advanceReadSeqScript = redis.NewScript(`
+local current = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local seq = tonumber(ARGV[2])
+if seq > current then
+	redis.call('HSET', KEYS[1], ARGV[1], seq)
+	return 1
+end
+return 0
+`)
+
+// readSeqKey 用户各会话已读位置的哈希表
+func readSeqKey(userID int) string {
+	return "read_seq:" + strconv.Itoa(userID)
+}
+
+// MarkConversationRead 标记会话已读，单聊时通知对方消息已被阅读
+func MarkConversationRead(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	convID := ctx.Param("id")
+	chatType, targetID, err := parseConversationID(convID, int(UserID))
+	if err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req request.ConversationRead
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := checkConversationAccess(ctx, int(UserID), chatType, targetID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxSeqs, err := getMaxSeqs(ctx, []string{convID})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	seq := req.Seq
+	if seq <= 0 || seq > maxSeqs[convID] {
+		seq = maxSeqs[convID]
+	}
+
+	advanced, err := setReadSeq(ctx, int(UserID), convID, seq)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 单聊中通知发送方消息已读
+	if advanced && chatType == model.SINGLE_CHAT {
+		receipt := gin.H{"conv_id": convID, "reader_id": int(UserID), "seq": seq}
+		env, err := delivery.NewEnvelope(strconv.Itoa(targetID), uuid.NewString(), delivery.EventRead, receipt)
+		if err == nil {
+			err = delivery.Deliver(ctx, env)
+		}
+		if err != nil {
+			log.Printf("发送已读回执给用户 %d 失败: %v", targetID, err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"conv": convID, "read_seq": seq})
+}
+
+// setReadSeq 推进用户在会话中的已读位置，返回是否发生了推进
+func setReadSeq(ctx context.Context, userID int, convID string, seq int64) (bool, error) {
+	if seq <= 0 {
+		return false, nil
+	}
+	redisCli := database.GetRedisClient()
+	db := database.GetDB()
+
+	advanced, err := advanceReadSeqScript.Run(ctx, redisCli, []string{readSeqKey(userID)}, convID, seq).Int()
+	if err != nil {
+		return false, err
+	}
+
+	record := model.ConversationRead{UserID: userID, ConvID: convID, ReadSeq: seq}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "conv_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"read_seq": gorm.Expr("GREATEST(read_seq, VALUES(read_seq))")}),
+	}).Create(&record).Error
+	if err != nil {
+		return false, err
+	}
+	return advanced == 1, nil
+}
+
+// getMaxSeqs 批量获取会话已分配的最大序列号
+func getMaxSeqs(ctx context.Context, convIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(convIDs))
+	if len(convIDs) == 0 {
+		return result, nil
+	}
+	var stored []model.ConversationSeq
+	if err := database.GetDB().WithContext(ctx).Where("conv_id IN ?", convIDs).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range stored {
+		result[s.ConvID] = s.Seq
+	}
+	return result, nil
+}
+
+// getReadSeqs 批量获取用户在各会话中的已读位置，缓存未命中时从 MySQL 读取并回填
+func getReadSeqs(ctx context.Context, userID int, convIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(convIDs))
+	if len(convIDs) == 0 {
+		return result, nil
+	}
+	redisCli := database.GetRedisClient()
+	cacheKey := readSeqKey(userID)
+
+	values, err := redisCli.HMGet(ctx, cacheKey, convIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			missing = append(missing, convIDs[i])
+			continue
+		}
+		seq, _ := strconv.ParseInt(str, 10, 64)
+		result[convIDs[i]] = seq
+	}
+
+	if len(missing) > 0 {
+		var stored []model.ConversationRead
+		if err := database.GetDB().Where("user_id = ? AND conv_id IN ?", userID, missing).Find(&stored).Error; err != nil {
+			return nil, err
+		}
+		pipe := redisCli.Pipeline()
+		for _, s := range stored {
+			result[s.ConvID] = s.ReadSeq
+			pipe.HSetNX(ctx, cacheKey, s.ConvID, s.ReadSeq)
+		}
+		if len(stored) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				log.Printf("缓存已读位置错误: %v", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// getUnreadCounts 批量计算用户在各会话中的未读消息数
+func getUnreadCounts(ctx context.Context, userID int, convIDs []string) (map[string]int64, error) {
+	maxSeqs, err := getMaxSeqs(ctx, convIDs)
+	if err != nil {
+		return nil, err
+	}
+	readSeqs, err := getReadSeqs(ctx, userID, convIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	unread := make(map[string]int64, len(convIDs))
+	for _, convID := range convIDs {
+		if n := maxSeqs[convID] - readSeqs[convID]; n > 0 {
+			unread[convID] = n
+		}
+	}
+	return unread, nil
+}
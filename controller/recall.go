@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRecallWindow = 2 * time.Minute  // 默认撤回时限
+	defaultEditWindow   = 15 * time.Minute // 默认编辑时限
+)
+
+var errAlreadyRecalled = errors.New("消息已撤回")
+
+// recallWindow 发送后允许撤回的时间，可通过 message.recallWindow 配置
+func recallWindow() time.Duration {
+	if window := viper.GetDuration("message.recallWindow"); window > 0 {
+		return window
+	}
+	return defaultRecallWindow
+}
+
+// editWindow 发送后允许编辑的时间，可通过 message.editWindow 配置
+func editWindow() time.Duration {
+	if window := viper.GetDuration("message.editWindow"); window > 0 {
+		return window
+	}
+	return defaultEditWindow
+}
+
+// RecallMessage 撤回消息
+// 发送者可以在时限内撤回自己的消息，群主和管理员可以随时撤回群内任意成员的消息
+func RecallMessage(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	db := database.GetDB()
+	msg, err := findMessage(db, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.Recalled {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息已撤回"})
+		return
+	}
+
+	isSender := msg.UserFrom == strconv.Itoa(int(UserID))
+	allowed := isSender && time.Since(time.Unix(msg.SendTime, 0)) <= recallWindow()
+	if !allowed && msg.ChatType == model.GROUP_CHAT {
+		groupID, _ := strconv.Atoi(msg.SendTarget)
+		role, err := getGroupRole(ctx, int(UserID), groupID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		allowed = isGroupManager(role)
+	}
+	if !allowed {
+		if isSender {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "已超过撤回时限"})
+			return
+		}
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "无权撤回该消息"})
+		return
+	}
+
+	// 撤回后只保留墓碑，清空消息内容、删除保存了旧内容的编辑历史，并释放对附件的引用
+	var orphaned []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.MyMessage{}).Where("message_id = ? AND recalled = ?", msg.MessageID, false).
+			Updates(map[string]interface{}{"recalled": true, "content": ""})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyRecalled
+		}
+		if err := tx.Unscoped().Where("message_id = ?", msg.MessageID).Delete(&model.MessageEdit{}).Error; err != nil {
+			return err
+		}
+		var err error
+		orphaned, err = releaseMessageAttachments(tx, msg)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errAlreadyRecalled) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	removeAttachmentObjects(ctx, orphaned)
+	msg.Recalled = true
+	msg.Content = ""
+
+	participants, err := conversationParticipants(ctx, msg)
+	if err != nil {
+		log.Printf("获取消息 %s 的会话参与者失败: %v", msg.MessageID, err)
+	}
+	refreshPendingMessage(ctx, participants, msg)
+	broadcastEvent(ctx, participants, delivery.EventRecall, gin.H{
+		"message_id":  msg.MessageID,
+		"conv_id":     msg.ConvID,
+		"seq":         msg.Seq,
+		"operator_id": int(UserID),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "撤回成功", "message_id": msg.MessageID})
+}
+
+// EditMessage 编辑自己发送的文本消息，保留编辑历史
+func EditMessage(ctx *gin.Context) {
+	var req request.MessageEdit
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Content == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息内容不能为空"})
+		return
+	}
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	db := database.GetDB()
+	msg, err := findMessage(db, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.UserFrom != strconv.Itoa(int(UserID)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "只能编辑自己发送的消息"})
+		return
+	}
+	if msg.Recalled {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息已撤回"})
+		return
+	}
+	if msg.Type != model.TEXT {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "只能编辑文本消息"})
+		return
+	}
+	if time.Since(time.Unix(msg.SendTime, 0)) > editWindow() {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "已超过编辑时限"})
+		return
+	}
+
+	editedAt := time.Now().Unix()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		edit := model.MessageEdit{
+			MessageID:  msg.MessageID,
+			EditorID:   int(UserID),
+			OldContent: msg.Content,
+			NewContent: req.Content,
+		}
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.MyMessage{}).Where("message_id = ?", msg.MessageID).
+			Updates(map[string]interface{}{"content": req.Content, "edited_at": editedAt}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	msg.Content = req.Content
+	msg.EditedAt = editedAt
+
+	participants, err := conversationParticipants(ctx, msg)
+	if err != nil {
+		log.Printf("获取消息 %s 的会话参与者失败: %v", msg.MessageID, err)
+	}
+	refreshPendingMessage(ctx, participants, msg)
+	broadcastEvent(ctx, participants, delivery.EventEdit, gin.H{
+		"message_id": msg.MessageID,
+		"conv_id":    msg.ConvID,
+		"seq":        msg.Seq,
+		"content":    msg.Content,
+		"edited_at":  msg.EditedAt,
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "编辑成功", "message_id": msg.MessageID, "edited_at": editedAt})
+}
+
+// GetMessageEdits 获取消息的编辑历史，只有会话参与者可以查看，已撤回的消息不再提供编辑历史
+func GetMessageEdits(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	db := database.GetDB()
+	msg, err := findMessage(db, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkMessageAccess(ctx, int(UserID), msg); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if msg.Recalled {
+		ctx.JSON(http.StatusGone, gin.H{"error": "消息已撤回"})
+		return
+	}
+
+	var edits []model.MessageEdit
+	if err := db.Where("message_id = ?", msg.MessageID).Order("id ASC").Find(&edits).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message_id": msg.MessageID, "edits": edits})
+}
+
+// findMessage 根据消息ID查询消息
+func findMessage(db *gorm.DB, messageID string) (model.MyMessage, error) {
+	var msg model.MyMessage
+	err := db.Where("message_id = ?", messageID).First(&msg).Error
+	return msg, err
+}
+
+// checkMessageAccess 检查用户是否可以查看消息所在的会话
+func checkMessageAccess(ctx context.Context, userID int, msg model.MyMessage) error {
+	chatType, targetID, err := parseConversationID(msg.ConvID, userID)
+	if err != nil {
+		return err
+	}
+	return checkConversationAccess(ctx, userID, chatType, targetID)
+}
+
+// getGroupRole 获取用户在群中的角色，不是群成员时返回空字符串
+func getGroupRole(ctx context.Context, userID, groupID int) (string, error) {
+	members, err := loadGroupMembers(ctx, database.GetRedisClient(), groupID)
+	if err != nil {
+		return "", err
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return member.Role, nil
+		}
+	}
+	return "", nil
+}
+
+// isGroupManager 判断角色是否为群主或管理员
+func isGroupManager(role string) bool {
+	return role == "owner" || role == "admin"
+}
+
+// refreshPendingMessage 用最新的消息内容替换参与者待确认队列中尚未收到的副本
+// 提及等高优先级副本保留原来的事件类型和优先级
+func refreshPendingMessage(ctx context.Context, participants []string, msg model.MyMessage) {
+	for _, userID := range participants {
+		if _, err := delivery.ReplacePendingData(ctx, userID, msg.MessageID, msg); err != nil {
+			log.Printf("更新用户 %s 待确认消息 %s 失败: %v", userID, msg.MessageID, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/model/request"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScheduleAhead = 30 * 24 * time.Hour // 定时消息默认最多提前 30 天创建
+	scheduleBatchSize    = 100                 // 每次扫描的到期定时消息数
+	scheduleSendTimeout  = 5 * time.Minute     // 处于发送中超过该时间的定时消息视为发送节点已崩溃
+)
+
+// scheduleAhead 定时消息最多可以提前多久创建，可通过 message.scheduleAhead 配置
+func scheduleAhead() time.Duration {
+	if ahead := viper.GetDuration("message.scheduleAhead"); ahead > 0 {
+		return ahead
+	}
+	return defaultScheduleAhead
+}
+
+// scheduleMessage 保存定时消息，到期后由 SendDueScheduledMessages 发送
+func scheduleMessage(msg model.MyMessage, sendAt int64) (model.ScheduledMessage, error) {
+	if time.Until(time.Unix(sendAt, 0)) > scheduleAhead() {
+		return model.ScheduledMessage{}, errors.New("定时发送时间太远")
+	}
+	scheduled := model.ScheduledMessage{
+		MessageID:  msg.MessageID,
+		UserFrom:   msg.UserFrom,
+		SendTarget: msg.SendTarget,
+		Content:    msg.Content,
+		Type:       msg.Type,
+		ChatType:   msg.ChatType,
+		ReplyTo:    msg.ReplyTo,
+		ThreadRoot: msg.ThreadRoot,
+		SendAt:     sendAt,
+		Status:     model.SchedulePending,
+	}
+	err := database.GetDB().Create(&scheduled).Error
+	return scheduled, err
+}
+
+// GetScheduledMessages 获取调用者尚未发送的定时消息
+func GetScheduledMessages(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	var scheduled []model.ScheduledMessage
+	if err := database.GetDB().
+		Where("user_from = ? AND status = ?", strconv.Itoa(int(UserID)), model.SchedulePending).
+		Order("send_at ASC").Find(&scheduled).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"scheduled": scheduled})
+}
+
+// CancelScheduledMessage 取消尚未发送的定时消息
+func CancelScheduledMessage(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	scheduleID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的定时消息ID"})
+		return
+	}
+
+	result := database.GetDB().Model(&model.ScheduledMessage{}).
+		Where("id = ? AND user_from = ? AND status = ?", scheduleID, strconv.Itoa(int(UserID)), model.SchedulePending).
+		Update("status", model.ScheduleCanceled)
+	if result.Error != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "定时消息不存在或已发送"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "定时消息已取消"})
+}
+
+// SendDueScheduledMessages 发送到期的定时消息，由 utils.InitPoll 中的定时任务调用
+// 每条消息先用条件更新从等待改为发送中，只有更新成功的节点发送；状态回写失败时记录停留在发送中，不会被再次发送
+// 认领没有使用 Redis 锁：认领与取消都是对同一行的条件更新，放在 MySQL 中二者互斥，
+// 而 Redis 锁过期或丢失后无法阻止已取消的消息被发送，也无法与状态记录原子地保持一致
+func SendDueScheduledMessages() {
+	ctx := context.Background()
+	db := database.GetDB()
+
+	recoverStaleScheduledMessages(db)
+
+	var due []model.ScheduledMessage
+	if err := db.Where("status = ? AND send_at <= ?", model.SchedulePending, time.Now().Unix()).
+		Order("send_at ASC").Limit(scheduleBatchSize).Find(&due).Error; err != nil {
+		log.Printf("查询到期定时消息失败: %v", err)
+		return
+	}
+
+	for _, scheduled := range due {
+		// 只认领仍处于等待状态的记录，已被取消或被其他节点认领的跳过
+		result := db.Model(&model.ScheduledMessage{}).
+			Where("id = ? AND status = ?", scheduled.ID, model.SchedulePending).
+			Update("status", model.ScheduleSending)
+		if result.Error != nil {
+			log.Printf("认领定时消息 %d 失败: %v", scheduled.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		status := model.ScheduleSent
+		if err := sendScheduledMessage(ctx, scheduled); err != nil {
+			log.Printf("发送定时消息 %d 失败: %v", scheduled.ID, err)
+			status = model.ScheduleFailed
+		}
+		if err := db.Model(&model.ScheduledMessage{}).
+			Where("id = ? AND status = ?", scheduled.ID, model.ScheduleSending).
+			Update("status", status).Error; err != nil {
+			log.Printf("更新定时消息 %d 状态失败: %v", scheduled.ID, err)
+		}
+	}
+}
+
+// recoverStaleScheduledMessages 处理发送节点崩溃后停留在发送中的定时消息
+// 消息已经写入 my_messages 的标记为已发送，否则退回等待状态重新发送；消息ID是主键，重复写入会失败，不会发送两次
+func recoverStaleScheduledMessages(db *gorm.DB) {
+	var stale []model.ScheduledMessage
+	if err := db.Where("status = ? AND updated_at < ?", model.ScheduleSending, time.Now().Add(-scheduleSendTimeout)).
+		Limit(scheduleBatchSize).Find(&stale).Error; err != nil {
+		log.Printf("查询发送中的定时消息失败: %v", err)
+		return
+	}
+	for _, scheduled := range stale {
+		var count int64
+		if err := db.Model(&model.MyMessage{}).Where("message_id = ?", scheduled.MessageID).Count(&count).Error; err != nil {
+			log.Printf("检查定时消息 %d 是否已发送失败: %v", scheduled.ID, err)
+			continue
+		}
+		status := model.SchedulePending
+		if count > 0 {
+			status = model.ScheduleSent
+		}
+		if err := db.Model(&model.ScheduledMessage{}).
+			Where("id = ? AND status = ?", scheduled.ID, model.ScheduleSending).
+			Update("status", status).Error; err != nil {
+			log.Printf("恢复定时消息 %d 状态失败: %v", scheduled.ID, err)
+		}
+	}
+}
+
+// sendScheduledMessage 按即时发送的流程重新校验并发送定时消息
+// 创建定时消息后可能已经被删除好友、移出群聊，被提及的成员也可能已经退群
+func sendScheduledMessage(ctx context.Context, scheduled model.ScheduledMessage) error {
+	userFrom, err := strconv.Atoi(scheduled.UserFrom)
+	if err != nil {
+		return err
+	}
+	target, err := strconv.Atoi(scheduled.SendTarget)
+	if err != nil {
+		return err
+	}
+
+	out, err := prepareMessage(ctx, uint(userFrom), scheduled.ChatType, request.MessageSend{
+		SendTarget: target,
+		Content:    scheduled.Content,
+		Type:       scheduled.Type,
+		ReplyTo:    scheduled.ReplyTo,
+		ThreadRoot: scheduled.ThreadRoot,
+	})
+	if err != nil {
+		return err
+	}
+	// 沿用创建定时消息时返回给客户端的消息ID
+	out.msg.MessageID = scheduled.MessageID
+	_, err = sendPrepared(ctx, out)
+	return err
+}
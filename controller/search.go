@@ -0,0 +1,262 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SearchQuery 消息搜索条件
+type SearchQuery struct {
+	Keywords []string           // 搜索关键词
+	ConvIDs  []string           // 限定搜索的会话ID，只会搜索调用者可见的会话
+	FromUser string             // 限定发送者用户ID，为空时不限
+	Type     *model.MessageType // 限定消息类型，为空时不限
+	Offset   int                // 分页偏移
+	Limit    int                // 每页条数
+}
+
+// SearchHit 一条搜索结果
+type SearchHit struct {
+	Message   model.MyMessage `json:"message"`
+	Highlight string          `json:"highlight"` // 关键词以 <em> 标记的消息内容
+}
+
+// MessageSearcher 消息搜索接口，默认实现使用 MySQL 全文索引，可以替换为其他索引服务
+type MessageSearcher interface {
+	Search(ctx context.Context, query SearchQuery) ([]SearchHit, int64, error)
+}
+
+var messageSearcher MessageSearcher = mysqlSearcher{}
+
+// SetMessageSearcher 替换消息搜索实现
+func SetMessageSearcher(searcher MessageSearcher) {
+	messageSearcher = searcher
+}
+
+// mysqlSearcher 基于 my_messages.content 上 ngram 全文索引的搜索实现
+type mysqlSearcher struct{}
+
+func (mysqlSearcher) Search(ctx context.Context, query SearchQuery) ([]SearchHit, int64, error) {
+	db := database.GetDB().WithContext(ctx)
+
+	// 布尔模式下每个关键词都必须出现，ngram 分词器会把关键词拆成短语匹配
+	terms := make([]string, len(query.Keywords))
+	for i, keyword := range query.Keywords {
+		terms[i] = `+"` + keyword + `"`
+	}
+	against := strings.Join(terms, " ")
+
+	base := db.Model(&model.MyMessage{}).
+		Where("MATCH(content) AGAINST(? IN BOOLEAN MODE)", against).
+		Where("conv_id IN ? AND recalled = ?", query.ConvIDs, false)
+	if query.FromUser != "" {
+		base = base.Where("user_from = ?", query.FromUser)
+	}
+	if query.Type != nil {
+		base = base.Where("type = ?", *query.Type)
+	}
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var messages []model.MyMessage
+	if err := base.Session(&gorm.Session{}).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "MATCH(content) AGAINST(? IN BOOLEAN MODE) DESC", Vars: []interface{}{against}}}).
+		Order("id DESC").
+		Offset(query.Offset).Limit(query.Limit).
+		Find(&messages).Error; err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]SearchHit, len(messages))
+	for i, msg := range messages {
+		hits[i] = SearchHit{Message: msg, Highlight: highlightContent(msg.Content, query.Keywords)}
+	}
+	return hits, total, nil
+}
+
+// SearchMessages 在调用者可见的单聊和群聊中全文搜索消息
+func SearchMessages(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	keywords := parseKeywords(ctx.Query("q"))
+	if len(keywords) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "搜索关键词不能为空"})
+		return
+	}
+
+	limit, err := parseLimit(ctx.Query("limit"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 page 参数"})
+		return
+	}
+
+	query := SearchQuery{
+		Keywords: keywords,
+		Offset:   (page - 1) * limit,
+		Limit:    limit,
+	}
+
+	if from := ctx.Query("from"); from != "" {
+		if _, err := strconv.Atoi(from); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 from 参数"})
+			return
+		}
+		query.FromUser = from
+	}
+	if typeStr := ctx.Query("type"); typeStr != "" {
+		t, err := strconv.Atoi(typeStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 type 参数"})
+			return
+		}
+		msgType := model.MessageType(t)
+		query.Type = &msgType
+	}
+
+	// 限定在指定会话或调用者全部可见的会话中搜索
+	if convID := ctx.Query("conv"); convID != "" {
+		chatType, targetID, err := parseConversationID(convID, int(UserID))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkConversationAccess(ctx, int(UserID), chatType, targetID); err != nil {
+			if errors.Is(err, errNotParticipant) {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		query.ConvIDs = []string{convID}
+	} else {
+		query.ConvIDs, err = visibleConversations(ctx, int(UserID))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if len(query.ConvIDs) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"results": []SearchHit{}, "total": 0, "page": page, "limit": limit})
+		return
+	}
+
+	hits, total, err := messageSearcher.Search(ctx, query)
+	if err != nil {
+		log.Printf("搜索消息失败: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"results": hits,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// visibleConversations 获取用户可见的全部会话ID：所有好友单聊和已加入的群聊
+func visibleConversations(ctx *gin.Context, userID int) ([]string, error) {
+	err, friends := GetFriends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err, groups := GetGroup(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	convIDs := make([]string, 0, len(friends)+len(groups))
+	for _, friend := range friends {
+		convIDs = append(convIDs, conversationID(model.SINGLE_CHAT, userID, int(friend.ID)))
+	}
+	for _, group := range groups {
+		convIDs = append(convIDs, conversationID(model.GROUP_CHAT, userID, group.GroupID))
+	}
+	return convIDs, nil
+}
+
+// parseKeywords 拆分搜索关键词，并去掉全文检索布尔模式下的运算符
+func parseKeywords(q string) []string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, q)
+	return strings.Fields(cleaned)
+}
+
+// highlightContent 在原始消息内容上标记关键词，再分段转义并用 <em> 包裹匹配的部分
+// 先转义再匹配会让关键词命中转义产生的实体（如 "amp" 命中 "&amp;"），并把实体从中间切开
+func highlightContent(content string, keywords []string) string {
+	lower := asciiLower(content)
+
+	// 标记每个关键词出现的位置
+	marked := make([]bool, len(content))
+	for _, keyword := range keywords {
+		k := asciiLower(keyword)
+		if k == "" {
+			continue
+		}
+		for start := 0; ; {
+			idx := strings.Index(lower[start:], k)
+			if idx < 0 {
+				break
+			}
+			for i := start + idx; i < start+idx+len(k); i++ {
+				marked[i] = true
+			}
+			start += idx + len(k)
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(content); {
+		end := start + 1
+		for end < len(content) && marked[end] == marked[start] {
+			end++
+		}
+		segment := html.EscapeString(content[start:end])
+		if marked[start] {
+			sb.WriteString("<em>")
+			sb.WriteString(segment)
+			sb.WriteString("</em>")
+		} else {
+			sb.WriteString(segment)
+		}
+		start = end
+	}
+	return sb.String()
+}
+
+// asciiLower 只转换 ASCII 字母的大小写，保证字节位置与原字符串一致
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
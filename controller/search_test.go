@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeywords(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"hello world", []string{"hello", "world"}},
+		{"  hello   ", []string{"hello"}},
+		{"+hello -world", []string{"hello", "world"}},
+		{`"exact phrase"`, []string{"exact", "phrase"}},
+		{"foo* (bar) <baz> ~qux @me", []string{"foo", "bar", "baz", "qux", "me"}},
+		{"你好 世界", []string{"你好", "世界"}},
+		{"+-*", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := parseKeywords(tt.q); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeywords(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		keywords []string
+		want     string
+	}{
+		{"单个关键词", "hello world", []string{"world"}, "hello <em>world</em>"},
+		{"忽略大小写", "Hello World", []string{"hello"}, "<em>Hello</em> World"},
+		{"多次出现", "go go go", []string{"go"}, "<em>go</em> <em>go</em> <em>go</em>"},
+		{"重叠的关键词合并", "abcdef", []string{"abc", "cde"}, "<em>abcde</em>f"},
+		{"相邻的关键词合并", "foobar", []string{"foo", "bar"}, "<em>foobar</em>"},
+		{"转义 HTML", "<b>hi</b>", []string{"hi"}, "&lt;b&gt;<em>hi</em>&lt;/b&gt;"},
+		{"关键词中的特殊字符", "a & b", []string{"&"}, "a <em>&amp;</em> b"},
+		{"关键词不匹配转义产生的实体", "a & b", []string{"amp"}, "a &amp; b"},
+		{"匹配部分转义后再包裹", "<a>", []string{"<a"}, "<em>&lt;a</em>&gt;"},
+		{"中文", "今天天气不错", []string{"天气"}, "今天<em>天气</em>不错"},
+		{"没有匹配", "hello", []string{"xyz"}, "hello"},
+		{"没有关键词", "hello", nil, "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightContent(tt.content, tt.keywords); got != tt.want {
+				t.Errorf("highlightContent(%q, %q) = %q, want %q", tt.content, tt.keywords, got, tt.want)
+			}
+		})
+	}
+}
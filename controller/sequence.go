@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// conversationID 生成会话ID：单聊为 s:<较小用户ID>_<较大用户ID>，群聊为 g:<群组ID>
+func conversationID(chatType model.ChatType, userID, targetID int) string {
+	if chatType == model.GROUP_CHAT {
+		return fmt.Sprintf("g:%d", targetID)
+	}
+	if userID > targetID {
+		userID, targetID = targetID, userID
+	}
+	return fmt.Sprintf("s:%d_%d", userID, targetID)
+}
+
+// parseConversationID 解析会话ID，单聊时返回调用者的对端用户ID
+func parseConversationID(convID string, userID int) (model.ChatType, int, error) {
+	switch {
+	case strings.HasPrefix(convID, "g:"):
+		groupID, err := strconv.Atoi(strings.TrimPrefix(convID, "g:"))
+		if err != nil || groupID <= 0 {
+			return 0, 0, errors.New("无效的会话ID")
+		}
+		return model.GROUP_CHAT, groupID, nil
+	case strings.HasPrefix(convID, "s:"):
+		ids := strings.Split(strings.TrimPrefix(convID, "s:"), "_")
+		if len(ids) != 2 {
+			return 0, 0, errors.New("无效的会话ID")
+		}
+		a, errA := strconv.Atoi(ids[0])
+		b, errB := strconv.Atoi(ids[1])
+		if errA != nil || errB != nil {
+			return 0, 0, errors.New("无效的会话ID")
+		}
+		switch userID {
+		case a:
+			return model.SINGLE_CHAT, b, nil
+		case b:
+			return model.SINGLE_CHAT, a, nil
+		}
+		return 0, 0, errNotParticipant
+	default:
+		return 0, 0, errors.New("无效的会话ID")
+	}
+}
+
+// nextSeq 在事务中为会话分配下一个序列号
+// conversation_seqs 中的会话行在事务提交前保持锁定，同一会话的消息依次分配，事务回滚时序列号一并回滚，不会留下空洞
+// 序列号不使用 Redis INCR 分配：Redis 中递增后消息写入失败会留下空洞，Redis 数据丢失后计数会回退，
+// 在 MySQL 中与消息同一事务分配才能保证序列号连续且不重复
+func nextSeq(tx *gorm.DB, convID string) (int64, error) {
+	record := model.ConversationSeq{ConvID: convID, Seq: 1}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conv_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"seq": gorm.Expr("seq + 1")}),
+	}).Create(&record).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var stored model.ConversationSeq
+	if err := tx.Where("conv_id = ?", convID).Take(&stored).Error; err != nil {
+		return 0, err
+	}
+	return stored.Seq, nil
+}
+
+// saveMessage 为消息分配会话ID和序列号后写入数据库
+// 序列号的分配和消息的写入在同一个事务中完成，写入失败时序列号不会被占用
+func saveMessage(ctx context.Context, msg *model.MyMessage) error {
+	db := database.GetDB()
+
+	userFrom, err := strconv.Atoi(msg.UserFrom)
+	if err != nil {
+		return err
+	}
+	target, err := strconv.Atoi(msg.SendTarget)
+	if err != nil {
+		return err
+	}
+	msg.ConvID = conversationID(msg.ChatType, userFrom, target)
+
+	// 会话开启阅后即焚时为消息设置过期时间
+	applyConversationTTL(ctx, msg)
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		seq, err := nextSeq(tx, msg.ConvID)
+		if err != nil {
+			return fmt.Errorf("分配序列号失败: %v", err)
+		}
+		msg.Seq = seq
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		return addAttachmentRefs(tx, attachmentKeys(*msg))
+	})
+	if err != nil {
+		msg.Seq = 0
+		if errors.Is(err, errAttachmentGone) {
+			return withStatus(http.StatusBadRequest, err)
+		}
+		return err
+	}
+
+	// 话题回复需要更新根消息的回复数和最后回复者
+	if msg.ThreadRoot != "" {
+		if err := bumpThreadRoot(db, msg); err != nil {
+			log.Printf("更新话题 %s 回复数失败: %v", msg.ThreadRoot, err)
+		}
+	}
+
+	// 发送者自己发出的消息视为已读
+	if _, err := setReadSeq(ctx, userFrom, msg.ConvID, msg.Seq); err != nil {
+		log.Printf("更新用户 %d 已读位置失败: %v", userFrom, err)
+	}
+	return nil
+}
+
+// SyncMessages 返回会话中序列号大于 after_seq 的消息，客户端据此补齐缺失的消息
+func SyncMessages(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	afterSeq, err := strconv.ParseInt(ctx.DefaultQuery("after_seq", "0"), 10, 64)
+	if err != nil || afterSeq < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的 after_seq 参数"})
+		return
+	}
+
+	limit, err := parseLimit(ctx.Query("limit"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := syncConversation(ctx, UserID, ctx.Query("conv"), afterSeq, limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// syncConversation 查询会话中序列号大于 afterSeq 的消息，HTTP 接口和 WebSocket 命令共用
+func syncConversation(ctx context.Context, UserID uint, convID string, afterSeq int64, limit int) (gin.H, error) {
+	chatType, targetID, err := parseConversationID(convID, int(UserID))
+	if err != nil {
+		if errors.Is(err, errNotParticipant) {
+			return nil, withStatus(http.StatusForbidden, err)
+		}
+		return nil, withStatus(http.StatusBadRequest, err)
+	}
+
+	if err := checkConversationAccess(ctx, int(UserID), chatType, targetID); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			return nil, withStatus(http.StatusForbidden, err)
+		}
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var messages []model.MyMessage
+	if err := db.Where("conv_id = ? AND seq > ?", convID, afterSeq).
+		Order("seq ASC").Limit(limit + 1).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// 当前会话已分配的最大序列号，客户端可以用来判断是否还有空洞
+	var stored model.ConversationSeq
+	if err := db.Where("conv_id = ?", convID).Limit(1).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	reactions, err := getReactionCounts(ctx, messageIDsOf(messages))
+	if err != nil {
+		log.Printf("获取表情计数失败: %v", err)
+	}
+
+	return gin.H{
+		"conv":      convID,
+		"messages":  messages,
+		"reactions": reactions,
+		"max_seq":   stored.Seq,
+		"has_more":  hasMore,
+	}, nil
+}
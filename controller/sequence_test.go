@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/helpleness/IMChatAdmin/model"
+)
+
+func TestConversationID(t *testing.T) {
+	tests := []struct {
+		chatType model.ChatType
+		userID   int
+		targetID int
+		want     string
+	}{
+		{model.SINGLE_CHAT, 1, 2, "s:1_2"},
+		{model.SINGLE_CHAT, 2, 1, "s:1_2"},
+		{model.GROUP_CHAT, 5, 9, "g:9"},
+	}
+	for _, tt := range tests {
+		if got := conversationID(tt.chatType, tt.userID, tt.targetID); got != tt.want {
+			t.Errorf("conversationID(%v, %d, %d) = %q, want %q", tt.chatType, tt.userID, tt.targetID, got, tt.want)
+		}
+	}
+}
+
+func TestParseConversationID(t *testing.T) {
+	tests := []struct {
+		name     string
+		convID   string
+		userID   int
+		chatType model.ChatType
+		targetID int
+		wantErr  bool
+	}{
+		{"群聊", "g:12", 3, model.GROUP_CHAT, 12, false},
+		{"单聊较小的一方", "s:3_7", 3, model.SINGLE_CHAT, 7, false},
+		{"单聊较大的一方", "s:3_7", 7, model.SINGLE_CHAT, 3, false},
+		{"群ID不是数字", "g:abc", 3, 0, 0, true},
+		{"群ID为0", "g:0", 3, 0, 0, true},
+		{"单聊缺少用户", "s:3", 3, 0, 0, true},
+		{"单聊用户不是数字", "s:3_x", 3, 0, 0, true},
+		{"未知前缀", "x:1", 1, 0, 0, true},
+		{"空字符串", "", 1, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatType, targetID, err := parseConversationID(tt.convID, tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseConversationID(%q) err = %v, wantErr %v", tt.convID, err, tt.wantErr)
+			}
+			if chatType != tt.chatType || targetID != tt.targetID {
+				t.Errorf("parseConversationID(%q) = %v, %d, want %v, %d", tt.convID, chatType, targetID, tt.chatType, tt.targetID)
+			}
+		})
+	}
+}
+
+func TestParseConversationIDNotParticipant(t *testing.T) {
+	if _, _, err := parseConversationID("s:3_7", 5); !errors.Is(err, errNotParticipant) {
+		t.Errorf("parseConversationID() err = %v, want %v", err, errNotParticipant)
+	}
+}
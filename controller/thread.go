@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// validateReplyRefs 检查引用的消息和话题根消息是否属于同一会话
+func validateReplyRefs(db *gorm.DB, convID, replyTo, threadRoot string) error {
+	if replyTo != "" {
+		quoted, err := findMessage(db, replyTo)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("引用的消息不存在")
+			}
+			return err
+		}
+		if quoted.ConvID != convID {
+			return errors.New("引用的消息不在当前会话中")
+		}
+		// 在话题中引用时，被引用的消息也必须在同一个话题里
+		if threadRoot != "" && quoted.MessageID != threadRoot && quoted.ThreadRoot != threadRoot {
+			return errors.New("引用的消息不在当前话题中")
+		}
+	}
+
+	if threadRoot != "" {
+		root, err := findMessage(db, threadRoot)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("话题根消息不存在")
+			}
+			return err
+		}
+		if root.ConvID != convID {
+			return errors.New("话题根消息不在当前会话中")
+		}
+		if root.ThreadRoot != "" {
+			return errors.New("不能在话题回复上再开话题")
+		}
+		if root.Recalled {
+			return errors.New("话题根消息已撤回")
+		}
+	}
+	return nil
+}
+
+// bumpThreadRoot 更新话题根消息的回复数和最后回复者
+func bumpThreadRoot(db *gorm.DB, reply *model.MyMessage) error {
+	return db.Model(&model.MyMessage{}).Where("message_id = ?", reply.ThreadRoot).
+		Updates(map[string]interface{}{
+			"reply_count":     gorm.Expr("reply_count + 1"),
+			"last_replier_id": reply.UserFrom,
+			"last_reply_at":   reply.SendTime,
+		}).Error
+}
+
+// GetThreadReplies 按时间顺序分页获取话题的回复
+func GetThreadReplies(ctx *gin.Context) {
+	ID, _ := ctx.Get("userid")
+	UserID := ID.(uint)
+
+	db := database.GetDB()
+	root, err := findMessage(db, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if root.ThreadRoot != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "该消息不是话题根消息"})
+		return
+	}
+	if err := checkMessageAccess(ctx, int(UserID), root); err != nil {
+		if errors.Is(err, errNotParticipant) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := parseLimit(ctx.Query("limit"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Where("thread_root = ?", root.MessageID)
+	if cursor := ctx.Query("after"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的游标"})
+			return
+		}
+		query = query.Where("id > ?", after)
+	}
+
+	var replies []model.MyMessage
+	if err := query.Order("id ASC").Limit(limit + 1).Find(&replies).Error; err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasMore := len(replies) > limit
+	if hasMore {
+		replies = replies[:limit]
+	}
+	nextCursor := ""
+	if hasMore {
+		nextCursor = encodeCursor(replies[len(replies)-1].ID)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"root":        root,
+		"replies":     replies,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	})
+}
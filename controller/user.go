@@ -117,7 +117,6 @@ func Login(ctx *gin.Context) {
 		},
 		"msg": "login success",
 	})
-	go PushMessage(ctx, user)
 	redisCli := database.GetRedisClient()
 	// 查找数据库中是否存在用户
 	cacheKey := "user:" + strconv.Itoa(int(user.ID))
@@ -0,0 +1,153 @@
+-- 消息功能的表结构变更，升级前在已有数据库上执行一次，兼容 MySQL 5.7.6 及以上版本（ngram 全文解析器从 5.7.6 起提供）
+-- 新部署可以直接取消 database/mysql.go 中 AutoMigrate 的注释，由 GORM 建表，不需要执行本脚本
+-- my_messages.send_time 仍是 DATETIME 的旧库还需要执行 migrate_send_time.sql，见 README
+
+-- my_messages 新增的列
+ALTER TABLE my_messages
+    ADD COLUMN chat_type INT DEFAULT 0,
+    ADD COLUMN conv_id VARCHAR(64),
+    ADD COLUMN seq BIGINT,
+    ADD COLUMN recalled TINYINT(1) DEFAULT 0,
+    ADD COLUMN edited_at BIGINT DEFAULT 0,
+    ADD COLUMN reply_to VARCHAR(36),
+    ADD COLUMN thread_root VARCHAR(36),
+    ADD COLUMN reply_count BIGINT DEFAULT 0,
+    ADD COLUMN last_replier_id VARCHAR(36),
+    ADD COLUMN last_reply_at BIGINT DEFAULT 0,
+    ADD COLUMN forwarded_from VARCHAR(36),
+    ADD COLUMN expire_at BIGINT DEFAULT 0;
+
+-- 会话内序列号唯一，已有消息的 conv_id 和 seq 为 NULL，不受唯一约束影响
+ALTER TABLE my_messages
+    ADD UNIQUE INDEX idx_conv_seq (conv_id, seq),
+    ADD INDEX idx_my_messages_reply_to (reply_to),
+    ADD INDEX idx_my_messages_thread_root (thread_root),
+    ADD INDEX idx_my_messages_expire_at (expire_at);
+
+-- 消息搜索使用的全文索引，ngram 分词器支持中文
+ALTER TABLE my_messages ADD FULLTEXT INDEX idx_content_fulltext (content) WITH PARSER ngram;
+
+-- 群公告
+ALTER TABLE `groups`
+    ADD COLUMN announcement TEXT,
+    ADD COLUMN announcement_by BIGINT DEFAULT 0,
+    ADD COLUMN announcement_at BIGINT DEFAULT 0;
+
+-- 文件的上传者
+ALTER TABLE files
+    ADD COLUMN user_id BIGINT UNSIGNED,
+    ADD INDEX idx_files_user_id (user_id);
+
+-- 新增的表
+CREATE TABLE IF NOT EXISTS conversation_seqs (
+    conv_id    VARCHAR(64) NOT NULL,
+    seq        BIGINT      NOT NULL DEFAULT 0,
+    updated_at DATETIME(3),
+    PRIMARY KEY (conv_id)
+);
+
+CREATE TABLE IF NOT EXISTS conversation_reads (
+    user_id    BIGINT      NOT NULL,
+    conv_id    VARCHAR(64) NOT NULL,
+    read_seq   BIGINT      NOT NULL DEFAULT 0,
+    updated_at DATETIME(3),
+    PRIMARY KEY (user_id, conv_id)
+);
+
+CREATE TABLE IF NOT EXISTS conversation_timers (
+    conv_id    VARCHAR(64) NOT NULL,
+    ttl        BIGINT      NOT NULL DEFAULT 0,
+    updated_by BIGINT      NOT NULL,
+    updated_at DATETIME(3),
+    PRIMARY KEY (conv_id)
+);
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    created_at  DATETIME(3),
+    updated_at  DATETIME(3),
+    deleted_at  DATETIME(3),
+    message_id  VARCHAR(36)     NOT NULL,
+    editor_id   BIGINT          NOT NULL,
+    old_content TEXT,
+    new_content TEXT,
+    PRIMARY KEY (id),
+    INDEX idx_message_edits_deleted_at (deleted_at),
+    INDEX idx_message_edits_message_id (message_id)
+);
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    message_id VARCHAR(36)     NOT NULL,
+    user_id    BIGINT          NOT NULL,
+    emoji      VARCHAR(32)     NOT NULL,
+    created_at DATETIME(3),
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_reaction (message_id, user_id, emoji)
+);
+
+CREATE TABLE IF NOT EXISTS message_mentions (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id     BIGINT          NOT NULL,
+    message_id  VARCHAR(36)     NOT NULL,
+    group_id    BIGINT          NOT NULL,
+    sender_id   BIGINT          NOT NULL,
+    mention_all TINYINT(1) DEFAULT 0,
+    `read`      TINYINT(1) DEFAULT 0,
+    created_at  DATETIME(3),
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_mention (user_id, message_id),
+    INDEX idx_mention_unread (user_id, `read`)
+);
+
+CREATE TABLE IF NOT EXISTS group_announcement_confirms (
+    group_id   BIGINT NOT NULL,
+    user_id    BIGINT NOT NULL,
+    version    BIGINT NOT NULL,
+    updated_at DATETIME(3),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    group_id   BIGINT          NOT NULL,
+    message_id VARCHAR(36)     NOT NULL,
+    pinned_by  BIGINT          NOT NULL,
+    created_at DATETIME(3),
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_pin (group_id, message_id)
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    message_id  VARCHAR(36)     NOT NULL,
+    user_from   VARCHAR(36)     NOT NULL,
+    send_target VARCHAR(36)     NOT NULL,
+    content     TEXT,
+    type        INT,
+    chat_type   INT DEFAULT 0,
+    reply_to    VARCHAR(36),
+    thread_root VARCHAR(36),
+    send_at     BIGINT          NOT NULL,
+    status      INT DEFAULT 0,
+    created_at  DATETIME(3),
+    updated_at  DATETIME(3),
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_scheduled_messages_message_id (message_id),
+    INDEX idx_scheduled_messages_user_from (user_from),
+    INDEX idx_schedule_due (status, send_at)
+);
+
+CREATE TABLE IF NOT EXISTS attachment_refs (
+    object_key VARCHAR(255) NOT NULL,
+    ref_count  BIGINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (object_key)
+);
+
+CREATE TABLE IF NOT EXISTS attachment_backfills (
+    id      BIGINT UNSIGNED NOT NULL,
+    end_id  BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    last_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    done    TINYINT(1)      NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
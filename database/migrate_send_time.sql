@@ -0,0 +1,12 @@
+-- 将 my_messages.send_time 从 DATETIME 转换为 Unix 时间戳（BIGINT），兼容 MySQL 5.7
+-- 只在 send_time 仍是日期类型时执行，先用下面的查询确认，返回 bigint 时不要执行，否则会把时间戳当作日期再转换一次：
+--   SELECT DATA_TYPE FROM information_schema.COLUMNS
+--   WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'my_messages' AND COLUMN_NAME = 'send_time';
+-- 转换期间停止写入消息；执行前备份 my_messages
+
+ALTER TABLE my_messages ADD COLUMN send_time_unix BIGINT;
+
+UPDATE my_messages SET send_time_unix = UNIX_TIMESTAMP(send_time) WHERE send_time IS NOT NULL;
+
+-- 确认 send_time_unix 已经全部填好后再删除旧列
+ALTER TABLE my_messages DROP COLUMN send_time, CHANGE COLUMN send_time_unix send_time BIGINT;
@@ -36,6 +36,18 @@ func InitMysql() *gorm.DB {
 	//db.AutoMigrate(&model.MyMessage{})
 	//db.AutoMigrate(&model.FriendAdd{})
 	//db.AutoMigrate(&model.GroupApplication{})
+	//db.AutoMigrate(&model.ConversationSeq{})
+	//db.AutoMigrate(&model.ConversationRead{})
+	//db.AutoMigrate(&model.MessageEdit{})
+	//db.AutoMigrate(&model.MessageReaction{})
+	//db.AutoMigrate(&model.MessageMention{})
+	//db.AutoMigrate(&model.GroupAnnouncementConfirm{})
+	//db.AutoMigrate(&model.PinnedMessage{})
+	//db.AutoMigrate(&model.ScheduledMessage{})
+	//db.AutoMigrate(&model.ConversationTimer{})
+	//db.AutoMigrate(&model.AttachmentRef{})
+	//db.AutoMigrate(&model.AttachmentBackfill{})
+	//已有数据库升级时的表结构变更见 database/migrate.sql
 	DB = db
 	return db
 }
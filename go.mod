@@ -8,16 +8,18 @@ require (
 	github.com/redis/go-redis/v9 v9.6.1
 	github.com/spf13/viper v1.13.0
 	golang.org/x/crypto v0.28.0
-	google.golang.org/grpc v1.64.1 // indirect; 或更高版本
-	google.golang.org/protobuf v1.34.2 // indirect; 或更高版本
+	google.golang.org/grpc v1.64.1 // 或更高版本
+	google.golang.org/protobuf v1.34.2 // 或更高版本
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.25.11
 )
 
 require (
 	github.com/golang-jwt/jwt/v4 v4.5.1
-	github.com/link1st/gowebsocket/v2 v2.0.8
+	github.com/google/uuid v1.6.0
 	github.com/minio/minio-go/v7 v7.0.79
+	github.com/panjf2000/ants/v2 v2.11.0
+	github.com/robfig/cron/v3 v3.0.1
 )
 
 require (
@@ -38,7 +40,6 @@ require (
 	github.com/go-playground/validator/v10 v10.20.0 // indirect
 	github.com/go-sql-driver/mysql v1.8.1 // indirect
 	github.com/goccy/go-json v0.10.3 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/hcl v1.0.1-0.20190611123218-cf7d376da96d // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -52,10 +53,8 @@ require (
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
-	github.com/panjf2000/ants/v2 v2.11.0 // indirect
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.2 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/rs/xid v1.6.0 // indirect
 	github.com/spf13/afero v1.10.0 // indirect
 	github.com/spf13/cast v1.6.0 // indirect
@@ -76,3 +75,9 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gorm.io/hints v1.1.2 // indirect
 )
+
+// 仅测试使用
+require (
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
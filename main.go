@@ -5,8 +5,12 @@ import (
 	"github.com/helpleness/IMChatAdmin/config"
 	"github.com/helpleness/IMChatAdmin/database"
 	"github.com/helpleness/IMChatAdmin/routers"
+	"github.com/helpleness/IMChatAdmin/service/websocket"
 	"github.com/helpleness/IMChatAdmin/utils"
 	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -29,6 +33,19 @@ func main() {
 	//初始化协程池，进行过期数据删除任务
 	utils.InitPoll()
 
+	//注册 WebSocket 命令并启动 WebSocket 服务和连接管理
+	routers.WebsocketInit()
+	go websocket.SocketStart()
+
+	//退出前从节点注册表中注销
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		websocket.Shutdown()
+		os.Exit(0)
+	}()
+
 	//如果端口不为空就加上端口运行
 	if port != "" {
 		panic(r.Run(":" + port))
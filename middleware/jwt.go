@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/helpleness/IMChatAdmin/database"
 	"github.com/helpleness/IMChatAdmin/model"
 	"time"
 )
@@ -41,3 +44,19 @@ func ParseToken(tokenString string) (*jwt.Token, *Claims, error) {
 	})
 	return token, claims, err
 }
+
+// UserFromToken 校验 token 并返回对应的用户，供 HTTP 以外的入口（如 WebSocket 握手）使用
+func UserFromToken(ctx context.Context, tokenString string) (model.User, error) {
+	token, claims, err := ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		return model.User{}, errors.New("token失效")
+	}
+	user, err := Isuserexist(ctx, int(claims.UserID), database.GetDB(), database.GetRedisClient())
+	if err != nil {
+		return model.User{}, err
+	}
+	if user.ID == 0 {
+		return model.User{}, errors.New("用户不存在")
+	}
+	return user, nil
+}
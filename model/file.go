@@ -6,4 +6,20 @@ type File struct {
 	gorm.Model
 	Name   string
 	Bucket string
+	UserID uint `gorm:"index"` // 上传者用户ID
+}
+
+// AttachmentRef 附件对象被消息引用的次数，消息保存、转发时增加，撤回、清理时减少，减到0时删除对象
+type AttachmentRef struct {
+	ObjectKey string `gorm:"primaryKey;type:varchar(255)"` // MinIO 中的对象名
+	RefCount  int64  `gorm:"not null;default:0"`           // 引用该对象的消息数
+}
+
+// AttachmentBackfill 从已有消息重建附件引用计数的进度，表中只有一行
+// 重建按消息ID分批进行，每批的计数和进度在同一事务中提交，进程中途退出后从 LastID 继续
+type AttachmentBackfill struct {
+	ID     uint `gorm:"primaryKey"`
+	EndID  uint `gorm:"not null;default:0"`     // 开始重建时最大的消息ID，之后保存的消息在发送时已经计数
+	LastID uint `gorm:"not null;default:0"`     // 已经计数的最大消息ID
+	Done   bool `gorm:"not null;default:false"` // 是否已经重建完成，完成前不删除任何附件
 }
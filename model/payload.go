@@ -0,0 +1,47 @@
+package model
+
+// 非文本消息的 Content 字段保存以下结构的 JSON
+
+// ImagePayload 图片消息内容
+type ImagePayload struct {
+	ObjectKey    string `json:"object_key"`              // 原图在 MinIO 中的对象名
+	Width        int    `json:"width"`                   // 图片宽度（像素）
+	Height       int    `json:"height"`                  // 图片高度（像素）
+	ThumbnailKey string `json:"thumbnail_key,omitempty"` // 缩略图在 MinIO 中的对象名
+}
+
+// FilePayload 文件消息内容
+type FilePayload struct {
+	ObjectKey string `json:"object_key"` // 文件在 MinIO 中的对象名
+	Size      int64  `json:"size"`       // 文件大小（字节）
+	SHA256    string `json:"sha256"`     // 文件内容的 SHA-256 十六进制摘要
+	Mime      string `json:"mime"`       // 文件 MIME 类型
+}
+
+// VoicePayload 语音消息内容
+type VoicePayload struct {
+	ObjectKey string `json:"object_key"` // 语音在 MinIO 中的对象名
+	Duration  int    `json:"duration"`   // 语音时长（秒）
+}
+
+// LocationPayload 位置消息内容
+type LocationPayload struct {
+	Lat   float64 `json:"lat"`             // 纬度
+	Lng   float64 `json:"lng"`             // 经度
+	Label string  `json:"label,omitempty"` // 位置名称
+}
+
+// MergedForwardPayload 合并转发消息内容，保存被转发消息的快照，客户端展开后显示
+type MergedForwardPayload struct {
+	Title string              `json:"title"` // 聊天记录标题
+	Items []MergedForwardItem `json:"items"` // 按发送顺序排列的消息
+}
+
+// MergedForwardItem 合并转发中的一条消息快照，附件沿用原消息中的对象名
+type MergedForwardItem struct {
+	MessageID string      `json:"message_id"` // 原消息ID
+	UserFrom  string      `json:"user_from"`  // 原发送者用户ID
+	Type      MessageType `json:"type"`       // 原消息类型
+	Content   string      `json:"content"`    // 原消息内容
+	SendTime  int64       `json:"send_time"`  // 原发送时间（Unix时间戳）
+}
@@ -0,0 +1,90 @@
+package request
+
+import "github.com/helpleness/IMChatAdmin/model"
+
+// MessageSend 表示发送单聊或群聊消息的请求
+type MessageSend struct {
+	SendTarget int               `json:"send_target"` // 接收者用户ID或群组ID
+	Content    string            `json:"content"`     // 消息内容
+	Type       model.MessageType `json:"type"`        // 消息类型
+	ReplyTo    string            `json:"reply_to"`    // 可选，引用回复的消息ID
+	ThreadRoot string            `json:"thread_root"` // 可选，发到该根消息的话题中
+	SendAt     int64             `json:"send_at"`     // 可选，定时发送的时间（Unix时间戳）
+}
+
+// MessageAck 表示确认已收到消息的请求
+type MessageAck struct {
+	MessageIDs []string `json:"message_ids"` // 已收到的消息ID列表
+}
+
+// ConversationRead 表示标记会话已读的请求
+type ConversationRead struct {
+	Seq int64 `json:"seq"` // 已读到的序列号，为0时表示读到最新
+}
+
+// MessageEdit 表示编辑消息的请求
+type MessageEdit struct {
+	Content string `json:"content"` // 新的消息内容
+}
+
+// MessageReaction 表示对消息添加表情回应的请求
+type MessageReaction struct {
+	Emoji string `json:"emoji"` // 表情
+}
+
+// MentionRead 表示标记提及已读的请求
+type MentionRead struct {
+	MentionIDs []uint `json:"mention_ids"` // 要标记已读的提及ID，为空时全部标记已读
+}
+
+// GroupAnnouncement 表示发布群公告的请求
+type GroupAnnouncement struct {
+	Content string `json:"content"` // 公告内容
+}
+
+// AnnouncementConfirm 表示确认群公告的请求
+type AnnouncementConfirm struct {
+	Version int64 `json:"version"` // 确认的公告版本号
+}
+
+// PinMessage 表示置顶群消息的请求
+type PinMessage struct {
+	MessageID string `json:"message_id"` // 要置顶的消息ID
+}
+
+// MessageForward 表示转发消息到单聊或群聊的请求
+type MessageForward struct {
+	MessageIDs []string       `json:"message_ids"` // 要转发的消息ID
+	ChatType   model.ChatType `json:"chat_type"`   // 目标会话类型
+	SendTarget int            `json:"send_target"` // 目标好友ID或群组ID
+	Merged     bool           `json:"merged"`      // 是否合并为一条聊天记录消息
+	Title      string         `json:"title"`       // 合并转发时的标题，为空时使用默认标题
+}
+
+// ConversationTimer 表示设置会话阅后即焚时间的请求
+type ConversationTimer struct {
+	TTL int64 `json:"ttl"` // 消息保留时间（秒），0表示关闭
+}
+
+// WsMessageSend 表示通过 WebSocket 发送消息的命令参数
+type WsMessageSend struct {
+	ChatType model.ChatType `json:"chat_type"` // 会话类型
+	MessageSend
+}
+
+// WsTyping 表示通过 WebSocket 发送正在输入状态的命令参数
+type WsTyping struct {
+	Conv string `json:"conv"` // 会话ID
+}
+
+// WsSync 表示通过 WebSocket 增量同步会话消息的命令参数
+type WsSync struct {
+	Conv     string `json:"conv"`      // 会话ID
+	AfterSeq int64  `json:"after_seq"` // 只返回序列号大于它的消息
+	Limit    int    `json:"limit"`     // 每页条数，为0时使用默认值
+}
+
+// WsHeartbeat 表示 WebSocket 心跳命令的参数
+type WsHeartbeat struct {
+	Status string `json:"status"` // 客户端状态，online 或 away，为空时视为 online
+}
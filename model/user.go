@@ -23,6 +23,17 @@ const (
 	FRIEND_REQUEST                    // 好友请求
 	GROUP_INVITE                      // 群组邀请
 	ONLINE_STATUS                     // 在线状态更新
+	VOICE                             // 语音消息
+	LOCATION                          // 位置消息
+	MERGED_FORWARD                    // 合并转发的聊天记录
+)
+
+// ChatType 描述消息所属的会话类型
+type ChatType int
+
+const (
+	SINGLE_CHAT ChatType = iota // 单聊
+	GROUP_CHAT                  // 群聊
 )
 
 type RequestStatus int
@@ -36,12 +47,105 @@ const (
 // MyMessage 聊天消息结构
 type MyMessage struct {
 	gorm.Model
-	MessageID  string      `gorm:"primaryKey;type:varchar(36)"` // 消息唯一标识
-	UserFrom   string      `gorm:"type:varchar(36);not null"`   // 发送者用户ID
-	SendTarget string      `gorm:"type:varchar(36);not null"`   // 接收者用户ID或群组ID
-	Content    string      `gorm:"type:text"`                   // 消息内容
-	Type       MessageType `gorm:"type:int"`                    // 消息类型
-	SendTime   time.Time   `gorm:"type:bigint"`                 // 发送时间（Unix时间戳）
+	MessageID     string      `gorm:"primaryKey;type:varchar(36)"`                                                  // 消息唯一标识
+	UserFrom      string      `gorm:"type:varchar(36);not null"`                                                    // 发送者用户ID
+	SendTarget    string      `gorm:"type:varchar(36);not null"`                                                    // 接收者用户ID或群组ID
+	Content       string      `gorm:"type:text;index:idx_content_fulltext,class:FULLTEXT,option:WITH PARSER ngram"` // 消息内容，使用 ngram 全文索引以支持中文搜索
+	Type          MessageType `gorm:"type:int"`                                                                     // 消息类型
+	ChatType      ChatType    `gorm:"type:int;default:0"`                                                           // 会话类型，单聊或群聊
+	ConvID        string      `gorm:"type:varchar(64);uniqueIndex:idx_conv_seq,priority:1"`                         // 会话ID
+	Seq           int64       `gorm:"uniqueIndex:idx_conv_seq,priority:2"`                                          // 会话内单调递增的序列号
+	SendTime      int64       `gorm:"type:bigint"`                                                                  // 发送时间（Unix时间戳）
+	Recalled      bool        `gorm:"default:false"`                                                                // 是否已撤回
+	EditedAt      int64       `gorm:"type:bigint;default:0"`                                                        // 最后编辑时间（Unix时间戳），0表示未编辑
+	ReplyTo       string      `gorm:"type:varchar(36);index"`                                                       // 引用回复的消息ID
+	ThreadRoot    string      `gorm:"type:varchar(36);index"`                                                       // 所属话题的根消息ID
+	ReplyCount    int         `gorm:"default:0"`                                                                    // 作为话题根消息时的回复数
+	LastReplierID string      `gorm:"type:varchar(36)"`                                                             // 作为话题根消息时最后回复的用户ID
+	LastReplyAt   int64       `gorm:"type:bigint;default:0"`                                                        // 作为话题根消息时最后回复的时间（Unix时间戳）
+	ForwardedFrom string      `gorm:"type:varchar(36)"`                                                             // 逐条转发时的原消息ID
+	ExpireAt      int64       `gorm:"type:bigint;default:0;index"`                                                  // 阅后即焚的过期时间（Unix时间戳），0表示不过期
+}
+
+// MessageEdit 消息的编辑历史
+type MessageEdit struct {
+	gorm.Model
+	MessageID  string `gorm:"type:varchar(36);index;not null"` // 被编辑的消息ID
+	EditorID   int    `gorm:"not null"`                        // 编辑者用户ID
+	OldContent string `gorm:"type:text"`                       // 编辑前的内容
+	NewContent string `gorm:"type:text"`                       // 编辑后的内容
+}
+
+// MessageReaction 用户对消息的表情回应，同一用户对同一消息的同一表情只记录一次
+type MessageReaction struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	MessageID string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_reaction,priority:1"` // 消息ID
+	UserID    int       `gorm:"not null;uniqueIndex:idx_reaction,priority:2"`                  // 回应的用户ID
+	Emoji     string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_reaction,priority:3"` // 表情
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// MessageMention 群消息中对用户的 @ 提及，构成用户的提及收件箱
+type MessageMention struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	UserID     int       `gorm:"not null;uniqueIndex:idx_mention,priority:1;index:idx_mention_unread,priority:1"` // 被提及的用户ID
+	MessageID  string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_mention,priority:2"`                    // 消息ID
+	GroupID    int       `gorm:"not null"`                                                                        // 群聊ID
+	SenderID   int       `gorm:"not null"`                                                                        // 发送者用户ID
+	MentionAll bool      `gorm:"default:false"`                                                                   // 是否通过 @all 提及
+	Read       bool      `gorm:"default:false;index:idx_mention_unread,priority:2"`                               // 是否已读
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// ScheduleStatus 定时消息的状态
+type ScheduleStatus int
+
+const (
+	SchedulePending  ScheduleStatus = iota // 0: 等待发送
+	ScheduleSent                           // 1: 已发送
+	ScheduleCanceled                       // 2: 已取消
+	ScheduleFailed                         // 3: 发送失败
+	ScheduleSending                        // 4: 已被定时任务认领，正在发送
+)
+
+// ScheduledMessage 定时发送的消息，到期后由定时任务写入 my_messages 并投递
+type ScheduledMessage struct {
+	ID         uint           `gorm:"primaryKey;autoIncrement"`
+	MessageID  string         `gorm:"type:varchar(36);uniqueIndex;not null"`                  // 发送时使用的消息ID
+	UserFrom   string         `gorm:"type:varchar(36);not null;index"`                        // 发送者用户ID
+	SendTarget string         `gorm:"type:varchar(36);not null"`                              // 接收者用户ID或群组ID
+	Content    string         `gorm:"type:text"`                                              // 消息内容
+	Type       MessageType    `gorm:"type:int"`                                               // 消息类型
+	ChatType   ChatType       `gorm:"type:int;default:0"`                                     // 会话类型
+	ReplyTo    string         `gorm:"type:varchar(36)"`                                       // 引用回复的消息ID
+	ThreadRoot string         `gorm:"type:varchar(36)"`                                       // 所属话题的根消息ID
+	SendAt     int64          `gorm:"type:bigint;not null;index:idx_schedule_due,priority:2"` // 计划发送时间（Unix时间戳）
+	Status     ScheduleStatus `gorm:"type:int;default:0;index:idx_schedule_due,priority:1"`   // 状态
+	CreatedAt  time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
+}
+
+// ConversationTimer 会话的阅后即焚设置，设置后新消息在 TTL 秒后自动删除
+type ConversationTimer struct {
+	ConvID    string    `gorm:"primaryKey;type:varchar(64)"` // 会话ID
+	TTL       int64     `gorm:"not null;default:0"`          // 消息保留时间（秒），0表示关闭
+	UpdatedBy int       `gorm:"not null"`                    // 最后修改设置的用户ID
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// ConversationSeq 会话已分配的最大序列号，消息写入时在同一事务中递增
+type ConversationSeq struct {
+	ConvID    string    `gorm:"primaryKey;type:varchar(64)"` // 会话ID
+	Seq       int64     `gorm:"not null;default:0"`          // 已分配的最大序列号
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// ConversationRead 用户在会话中的已读位置
+type ConversationRead struct {
+	UserID    int       `gorm:"primaryKey;not null"`         // 用户ID
+	ConvID    string    `gorm:"primaryKey;type:varchar(64)"` // 会话ID
+	ReadSeq   int64     `gorm:"not null;default:0"`          // 已读到的最大序列号
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
 
 // 定义 Friends 结构体，好友关系表
@@ -56,10 +160,13 @@ type Friends struct {
 
 // Group 表示群聊的基本信息
 type Group struct {
-	GroupID     int       `gorm:"primaryKey;not null;autoIncrement"` // 群聊唯一ID，使用字符串
-	GroupName   string    `gorm:"type:varchar(100);not null"`        // 群聊名称，最长100字符
-	OwnerID     int       `gorm:"not null"`                          // 群主的用户ID
-	CreatedTime time.Time `gorm:"autoCreateTime"`                    // 群聊创建时间
+	GroupID        int       `gorm:"primaryKey;not null;autoIncrement"` // 群聊唯一ID，使用字符串
+	GroupName      string    `gorm:"type:varchar(100);not null"`        // 群聊名称，最长100字符
+	OwnerID        int       `gorm:"not null"`                          // 群主的用户ID
+	CreatedTime    time.Time `gorm:"autoCreateTime"`                    // 群聊创建时间
+	Announcement   string    `gorm:"type:text"`                         // 群公告内容
+	AnnouncementBy int       `gorm:"default:0"`                         // 发布群公告的用户ID
+	AnnouncementAt int64     `gorm:"type:bigint;default:0"`             // 群公告发布时间（Unix毫秒），同时作为公告版本号
 	//Members     []GroupMember `gorm:"foreignKey:GroupID;references:GroupID;constraint:OnDelete:CASCADE"` // 群聊成员列表，外键关联
 }
 
@@ -72,6 +179,23 @@ type GroupMember struct {
 	Role     string    `gorm:"type:varchar(20);not null"` // 成员角色，例如 "owner", "admin", "member"
 }
 
+// GroupAnnouncementConfirm 成员对群公告的确认记录
+type GroupAnnouncementConfirm struct {
+	GroupID   int       `gorm:"primaryKey;not null"`  // 群聊ID
+	UserID    int       `gorm:"primaryKey;not null"`  // 成员的用户ID
+	Version   int64     `gorm:"type:bigint;not null"` // 已确认的公告版本号
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// PinnedMessage 群聊中被置顶的消息
+type PinnedMessage struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	GroupID   int       `gorm:"not null;uniqueIndex:idx_pin,priority:1"`                  // 群聊ID
+	MessageID string    `gorm:"type:varchar(36);not null;uniqueIndex:idx_pin,priority:2"` // 被置顶的消息ID
+	PinnedBy  int       `gorm:"not null"`                                                 // 置顶操作者用户ID
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
 // FriendAdd 表示添加好友的请求
 type FriendAdd struct {
 	gorm.Model
@@ -2,12 +2,17 @@
 package routers
 
 import (
-	"github.com/link1st/gowebsocket/v2/servers/websocket"
+	"github.com/helpleness/IMChatAdmin/controller"
+	"github.com/helpleness/IMChatAdmin/service/websocket"
 )
 
 // WebsocketInit Websocket 路由
 func WebsocketInit() {
-	websocket.Register("login", websocket.LoginController)
-	websocket.Register("heartbeat", websocket.HeartbeatController)
-	websocket.Register("ping", websocket.PingController)
+	websocket.Register("login", controller.LoginController)
+	websocket.Register("heartbeat", controller.HeartbeatController)
+	websocket.Register("ping", controller.PingController)
+	websocket.Register("send-message", controller.SendMessageController)
+	websocket.Register("ack", controller.AckController)
+	websocket.Register("typing", controller.TypingController)
+	websocket.Register("sync", controller.SyncController)
 }
@@ -34,5 +34,33 @@ func Collectrouters(r *gin.Engine) *gin.Engine {
 	r.GET("/groups", middleware.AuthMiddleWare(), controller.GetGroupHandler)
 	r.GET("/friends", middleware.AuthMiddleWare(), controller.GetFriendsHandler)
 	r.GET("/GetGroupMembers", middleware.AuthMiddleWare(), controller.GetGroupMembers)
+	r.POST("/messages", middleware.AuthMiddleWare(), controller.SendMessage)                                // 发送单聊消息
+	r.POST("/messages/group", middleware.AuthMiddleWare(), controller.SendGroupMessage)                     // 发送群聊消息
+	r.GET("/messages/history", middleware.AuthMiddleWare(), controller.GetMessageHistory)                   // 游标分页获取会话历史消息
+	r.GET("/messages/sync", middleware.AuthMiddleWare(), controller.SyncMessages)                           // 按序列号增量同步会话消息
+	r.POST("/messages/ack", middleware.AuthMiddleWare(), controller.AckMessages)                            // 确认已收到消息
+	r.POST("/conversations/:id/read", middleware.AuthMiddleWare(), controller.MarkConversationRead)         // 标记会话已读
+	r.POST("/messages/:id/recall", middleware.AuthMiddleWare(), controller.RecallMessage)                   // 撤回消息
+	r.POST("/messages/:id/edit", middleware.AuthMiddleWare(), controller.EditMessage)                       // 编辑消息
+	r.GET("/messages/:id/edits", middleware.AuthMiddleWare(), controller.GetMessageEdits)                   // 获取消息编辑历史
+	r.GET("/messages/search", middleware.AuthMiddleWare(), controller.SearchMessages)                       // 全文搜索消息
+	r.POST("/messages/:id/reactions", middleware.AuthMiddleWare(), controller.AddReaction)                  // 添加表情回应
+	r.DELETE("/messages/:id/reactions", middleware.AuthMiddleWare(), controller.RemoveReaction)             // 取消表情回应
+	r.GET("/messages/:id/thread", middleware.AuthMiddleWare(), controller.GetThreadReplies)                 // 分页获取话题回复
+	r.GET("/mentions", middleware.AuthMiddleWare(), controller.GetMentions)                                 // 分页获取提及收件箱
+	r.POST("/mentions/read", middleware.AuthMiddleWare(), controller.ReadMentions)                          // 标记提及已读
+	r.POST("/groups/:id/announcement", middleware.AuthMiddleWare(), controller.PostAnnouncement)            // 发布群公告
+	r.GET("/groups/:id/announcement", middleware.AuthMiddleWare(), controller.GetAnnouncement)              // 获取群公告
+	r.POST("/groups/:id/announcement/confirm", middleware.AuthMiddleWare(), controller.ConfirmAnnouncement) // 确认群公告
+	r.GET("/groups/:id/pins", middleware.AuthMiddleWare(), controller.GetPinnedMessages)                    // 获取群置顶消息
+	r.POST("/groups/:id/pins", middleware.AuthMiddleWare(), controller.PinMessage)                          // 置顶群消息
+	r.DELETE("/groups/:id/pins/:messageId", middleware.AuthMiddleWare(), controller.UnpinMessage)           // 取消置顶群消息
+	r.POST("/messages/forward", middleware.AuthMiddleWare(), controller.ForwardMessages)                    // 转发消息或合并转发
+	r.GET("/messages/scheduled", middleware.AuthMiddleWare(), controller.GetScheduledMessages)              // 获取未发送的定时消息
+	r.DELETE("/messages/scheduled/:id", middleware.AuthMiddleWare(), controller.CancelScheduledMessage)     // 取消定时消息
+	r.GET("/conversations/:id/timer", middleware.AuthMiddleWare(), controller.GetConversationTimer)         // 获取会话阅后即焚设置
+	r.POST("/conversations/:id/timer", middleware.AuthMiddleWare(), controller.SetConversationTimer)        // 设置会话阅后即焚
+	r.GET("/presence", middleware.AuthMiddleWare(), controller.GetPresence)                                 // 批量查询用户在线状态
+	r.GET("/ws/endpoint", middleware.AuthMiddleWare(), controller.GetWsEndpoint)                            // 分配 WebSocket 连接节点
 	return r
 }
@@ -0,0 +1,487 @@
+// Package delivery 负责消息投递：在线用户推送到所在节点的消息流，所有消息在客户端确认前保存在待确认队列中
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/service/grpcclient"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	EventMessage      = "message"      // 聊天消息
+	EventRead         = "read"         // 已读回执
+	EventRecall       = "recall"       // 消息撤回
+	EventEdit         = "edit"         // 消息编辑
+	EventReact        = "react"        // 表情回应变化
+	EventMention      = "mention"      // 群消息中提及了接收者
+	EventAnnouncement = "announcement" // 群公告
+	EventPin          = "pin"          // 群消息置顶或取消置顶
+	EventTimer        = "timer"        // 会话阅后即焚设置变化
+	EventPurge        = "purge"        // 过期消息已删除，客户端需要删除本地副本
+	EventTyping       = "typing"       // 对方正在输入
+
+	defaultPendingTTL = 7 * 24 * time.Hour // 待确认消息默认保留时间
+)
+
+// Envelope 投递到节点消息流和待确认队列中的消息信封
+type Envelope struct {
+	UserID    string          `json:"user_id"`    // 接收者用户ID
+	MessageID string          `json:"message_id"` // 消息ID，客户端据此确认
+	Event     string          `json:"event"`      // 事件类型
+	Data      json.RawMessage `json:"data"`       // 事件内容
+	Priority  bool            `json:"priority"`   // 高优先级消息在重新投递时排在最前面
+	Transient bool            `json:"transient"`  // 不在待确认队列中的事件，慢速客户端可以直接丢弃
+}
+
+// NewEnvelope 构造发给指定用户的信封
+func NewEnvelope(userID, messageID, event string, data interface{}) (Envelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{UserID: userID, MessageID: messageID, Event: event, Data: raw}, nil
+}
+
+// pendingTTL 待确认消息的保留时间，可通过 message.pendingTTL 配置
+func pendingTTL() time.Duration {
+	if ttl := viper.GetDuration("message.pendingTTL"); ttl > 0 {
+		return ttl
+	}
+	return defaultPendingTTL
+}
+
+// pendingKey 待确认消息ID的有序集合，分数为入队时间（毫秒）
+func pendingKey(userID string) string {
+	return "pending_ack:" + userID
+}
+
+// priorityKey 高优先级待确认消息ID的有序集合，分数同样为入队时间（毫秒）
+func priorityKey(userID string) string {
+	return "pending_ack_priority:" + userID
+}
+
+// pendingDataKey 待确认消息内容的哈希表
+func pendingDataKey(userID string) string {
+	return "pending_ack_data:" + userID
+}
+
+// deviceKey 用户登录过的设备（平台）的有序集合，分数为最近一次登录的时间（毫秒）
+// 超过待确认消息保留时间没有登录的设备不再参与确认
+func deviceKey(userID string) string {
+	return "pending_devices:" + userID
+}
+
+// ackedKey 设备已确认但其他设备尚未全部确认的消息ID集合
+func ackedKey(userID string, appID uint32) string {
+	return fmt.Sprintf("pending_acked:%s:%d", userID, appID)
+}
+
+// Enqueue 将消息放入用户的待确认队列，直到客户端确认或过期才会移除
+func Enqueue(ctx context.Context, env Envelope) error {
+	redisCli := database.GetRedisClient()
+	envMarshal, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	ttl := pendingTTL()
+	member := redis.Z{Score: float64(time.Now().UnixMilli()), Member: env.MessageID}
+	pipe := redisCli.TxPipeline()
+	if env.Priority {
+		// 同一条消息只保留在一个队列中
+		pipe.ZRem(ctx, pendingKey(env.UserID), env.MessageID)
+		pipe.ZAddNX(ctx, priorityKey(env.UserID), member)
+		pipe.Expire(ctx, priorityKey(env.UserID), ttl)
+	} else {
+		pipe.ZAddNX(ctx, pendingKey(env.UserID), member)
+		pipe.Expire(ctx, pendingKey(env.UserID), ttl)
+	}
+	pipe.HSet(ctx, pendingDataKey(env.UserID), env.MessageID, envMarshal)
+	pipe.Expire(ctx, pendingDataKey(env.UserID), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Deliver 投递消息：先写入待确认队列，用户在线时再推送到其所在节点
+func Deliver(ctx context.Context, env Envelope) error {
+	if err := Enqueue(ctx, env); err != nil {
+		return err
+	}
+	// 消息已在待确认队列中，用户离线时上线后会重新投递
+	return push(ctx, env)
+}
+
+// replacePendingRetries 替换待确认消息内容时乐观锁冲突的最大重试次数
+const replacePendingRetries = 3
+
+// ReplacePendingData 替换用户待确认队列中尚未确认的消息内容，消息已确认时不做任何事
+// 只替换信封中的 Data，事件类型和优先级保持入队时的值；用于撤回、编辑等场景，离线用户上线后直接收到最新的内容
+func ReplacePendingData(ctx context.Context, userID, messageID string, data interface{}) (bool, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	redisCli := database.GetRedisClient()
+	dataKey := pendingDataKey(userID)
+
+	replaced := false
+	replace := func(tx *redis.Tx) error {
+		envJSON, err := tx.HGet(ctx, dataKey, messageID).Result()
+		if err == redis.Nil {
+			// 消息已确认或已过期
+			replaced = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(envJSON), &env); err != nil {
+			return err
+		}
+		env.Data = raw
+		envMarshal, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+		// 读取之后消息被确认或被其他请求替换时事务失败，重新读取
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, dataKey, messageID, envMarshal)
+			return nil
+		})
+		replaced = err == nil
+		return err
+	}
+
+	for i := 0; i < replacePendingRetries; i++ {
+		err = redisCli.Watch(ctx, replace, dataKey)
+		if err != redis.TxFailedErr {
+			return replaced, err
+		}
+	}
+	return false, err
+}
+
+// Notify 尽力投递：只推送给在线用户，不进入待确认队列，用户离线时直接丢弃
+// 用于表情回应等客户端可以从接口重新拉取的事件
+func Notify(ctx context.Context, env Envelope) error {
+	env.Transient = true
+	return push(ctx, env)
+}
+
+// push 用户在线时将信封推送到其所在的每个节点
+// 优先通过 gRPC 直接推送，调用失败时写入该节点的消息流，由节点的消费者组投递
+func push(ctx context.Context, env Envelope) error {
+	redisCli := database.GetRedisClient()
+	nodes, err := UserNodes(ctx, redisCli, env.UserID)
+	if err != nil || len(nodes) == 0 {
+		return err
+	}
+
+	envMarshal, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, node := range nodes {
+		// 用户已经离开该节点时不投递，其他节点上的连接仍会收到
+		if _, err := grpcclient.DeliverToUser(ctx, node, env.UserID, envMarshal); err != nil {
+			log.Printf("通过 gRPC 推送到节点 %s 失败，改为写入消息流: %v", node, err)
+			if err := publish(ctx, redisCli, node, envMarshal); err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// NotifyGroup 尽力向一组用户推送同一个事件，不进入待确认队列
+// 按用户所在节点分组，每个节点调用一次 gRPC，调用失败时改为写入该节点的消息流；groupID 仅用于日志，非群组事件为0
+func NotifyGroup(ctx context.Context, groupID int, userIDs []string, env Envelope) {
+	redisCli := database.GetRedisClient()
+	env.Transient = true
+
+	byNode := make(map[string][]string)
+	for _, userID := range userIDs {
+		nodes, err := UserNodes(ctx, redisCli, userID)
+		if err != nil {
+			log.Printf("查询用户 %s 所在节点失败: %v", userID, err)
+			continue
+		}
+		for _, node := range nodes {
+			byNode[node] = append(byNode[node], userID)
+		}
+	}
+
+	for node, members := range byNode {
+		env.UserID = ""
+		envMarshal, err := json.Marshal(env)
+		if err != nil {
+			log.Printf("序列化 %s 事件失败: %v", env.Event, err)
+			return
+		}
+		if _, err := grpcclient.BroadcastToGroup(ctx, node, groupID, members, envMarshal); err == nil {
+			continue
+		} else {
+			log.Printf("通过 gRPC 向节点 %s 广播群组 %d 的 %s 事件失败，改为写入消息流: %v", node, groupID, env.Event, err)
+		}
+
+		pipe := redisCli.Pipeline()
+		for _, userID := range members {
+			env.UserID = userID
+			if envMarshal, err := json.Marshal(env); err == nil {
+				_ = publish(ctx, pipe, node, envMarshal)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("写入节点 %s 消息流失败: %v", node, err)
+		}
+	}
+}
+
+// Reroute 用户已经不在 fromNode 上时，将节点流中读取的信封转发到用户当前所在的其他存活节点
+// 用户离线或只登记在已失效的节点上时返回 false，必须送达的消息仍在待确认队列中，上线后重新投递
+func Reroute(ctx context.Context, env Envelope, fromNode string) (bool, error) {
+	redisCli := database.GetRedisClient()
+	nodes, err := UserNodes(ctx, redisCli, env.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	envMarshal, err := json.Marshal(env)
+	if err != nil {
+		return false, err
+	}
+	rerouted := false
+	for _, node := range nodes {
+		if node == fromNode {
+			continue
+		}
+		alive, err := isNodeAlive(ctx, redisCli, node)
+		if err != nil || !alive {
+			continue
+		}
+		if err := publish(ctx, redisCli, node, envMarshal); err != nil {
+			return rerouted, err
+		}
+		rerouted = true
+	}
+	return rerouted, nil
+}
+
+// RegisterDevice 记录用户在 appID 平台上登录，此后投递给用户的消息需要该设备也确认后才从队列中移除
+// 同时清理超过保留时间没有登录的设备及其确认记录
+func RegisterDevice(ctx context.Context, userID string, appID uint32) error {
+	redisCli := database.GetRedisClient()
+	ttl := pendingTTL()
+	now := time.Now()
+
+	stale, err := redisCli.ZRangeByScore(ctx, deviceKey(userID), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Add(-ttl).UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := redisCli.TxPipeline()
+	for _, device := range stale {
+		pipe.ZRem(ctx, deviceKey(userID), device)
+		if staleID, err := strconv.ParseUint(device, 10, 32); err == nil {
+			pipe.Del(ctx, ackedKey(userID, uint32(staleID)))
+		}
+	}
+	pipe.ZAdd(ctx, deviceKey(userID), redis.Z{Score: float64(now.UnixMilli()), Member: appID})
+	pipe.Expire(ctx, deviceKey(userID), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// devices 返回用户仍在保留时间内登录过的设备
+func devices(ctx context.Context, redisCli *redis.Client, userID string) ([]uint32, error) {
+	members, err := redisCli.ZRangeByScore(ctx, deviceKey(userID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().Add(-pendingTTL()).UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	appIDs := make([]uint32, 0, len(members))
+	for _, member := range members {
+		if appID, err := strconv.ParseUint(member, 10, 32); err == nil {
+			appIDs = append(appIDs, uint32(appID))
+		}
+	}
+	return appIDs, nil
+}
+
+// ackScript 记录设备确认了哪些消息，所有设备都确认后才从待确认队列中移除
+// KEYS: 普通队列、高优先级队列、消息内容、本设备的确认集合、其他设备的确认集合...；ARGV: 确认集合过期秒数、消息ID...
+// 返回本设备新确认的条数
+var ackScript = redis.NewScript(`
+local acked = 0
+for i = 2, #ARGV do
+	local id = ARGV[i]
+	if redis.call('HEXISTS', KEYS[3], id) == 1 then
+		acked = acked + redis.call('SADD', KEYS[4], id)
+		local all = true
+		for k = 5, #KEYS do
+			if redis.call('SISMEMBER', KEYS[k], id) == 0 then
+				all = false
+				break
+			end
+		end
+		if all then
+			redis.call('ZREM', KEYS[1], id)
+			redis.call('ZREM', KEYS[2], id)
+			redis.call('HDEL', KEYS[3], id)
+			for k = 4, #KEYS do
+				redis.call('SREM', KEYS[k], id)
+			end
+		end
+	end
+end
+redis.call('EXPIRE', KEYS[4], ARGV[1])
+return acked
+`)
+
+// Ack 设备确认消息已收到，返回本设备新确认的条数
+// 消息在用户所有登录过的设备都确认后才从待确认队列中移除，一个设备确认不影响向其他设备重新投递
+func Ack(ctx context.Context, userID string, appID uint32, messageIDs ...string) (int64, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+	redisCli := database.GetRedisClient()
+
+	appIDs, err := devices(ctx, redisCli, userID)
+	if err != nil {
+		return 0, err
+	}
+	keys := []string{pendingKey(userID), priorityKey(userID), pendingDataKey(userID), ackedKey(userID, appID)}
+	for _, other := range appIDs {
+		if other != appID {
+			keys = append(keys, ackedKey(userID, other))
+		}
+	}
+	args := make([]interface{}, 0, len(messageIDs)+1)
+	args = append(args, int64(pendingTTL()/time.Second))
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	return ackScript.Run(ctx, redisCli, keys, args...).Int64()
+}
+
+// Remove 不论设备是否确认，直接从用户的待确认队列中移除消息，返回实际移除的条数
+// 用于过期消息的清理，这些消息不再需要投递
+func Remove(ctx context.Context, userID string, messageIDs ...string) (int64, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+	redisCli := database.GetRedisClient()
+	appIDs, err := devices(ctx, redisCli, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	members := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		members[i] = id
+	}
+
+	pipe := redisCli.TxPipeline()
+	removed := pipe.ZRem(ctx, pendingKey(userID), members...)
+	removedPriority := pipe.ZRem(ctx, priorityKey(userID), members...)
+	pipe.HDel(ctx, pendingDataKey(userID), messageIDs...)
+	for _, appID := range appIDs {
+		pipe.SRem(ctx, ackedKey(userID, appID), members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return removed.Val() + removedPriority.Val(), nil
+}
+
+// Pending 返回设备 appID 尚未确认的待确认消息，过期消息会被清理
+// 高优先级消息排在最前面，同一优先级内按入队顺序排列
+func Pending(ctx context.Context, userID string, appID uint32) ([]Envelope, error) {
+	redisCli := database.GetRedisClient()
+	expireBefore := time.Now().Add(-pendingTTL()).UnixMilli()
+
+	var messageIDs []string
+	for _, key := range []string{priorityKey(userID), pendingKey(userID)} {
+		// 清理超过保留时间的消息
+		expired, err := redisCli.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(expireBefore, 10),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(expired) > 0 {
+			if _, err := Remove(ctx, userID, expired...); err != nil {
+				log.Printf("清理用户 %s 过期消息失败: %v", userID, err)
+			}
+		}
+
+		ids, err := redisCli.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		messageIDs = append(messageIDs, ids...)
+	}
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	// 跳过本设备已经确认的消息
+	acked, err := redisCli.SMembers(ctx, ackedKey(userID, appID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(acked) > 0 {
+		ackedSet := make(map[string]bool, len(acked))
+		for _, id := range acked {
+			ackedSet[id] = true
+		}
+		unacked := messageIDs[:0]
+		for _, id := range messageIDs {
+			if !ackedSet[id] {
+				unacked = append(unacked, id)
+			}
+		}
+		messageIDs = unacked
+		if len(messageIDs) == 0 {
+			return nil, nil
+		}
+	}
+
+	values, err := redisCli.HMGet(ctx, pendingDataKey(userID), messageIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]Envelope, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// 内容已丢失的消息无法重投，直接移除索引
+			redisCli.ZRem(ctx, pendingKey(userID), messageIDs[i])
+			redisCli.ZRem(ctx, priorityKey(userID), messageIDs[i])
+			continue
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(data), &env); err != nil {
+			log.Printf("解析用户 %s 待确认消息失败: %v", userID, err)
+			continue
+		}
+		envelopes = append(envelopes, env)
+	}
+	return envelopes, nil
+}
@@ -0,0 +1,306 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupRedis 用 miniredis 替换全局 Redis 客户端
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.RedisClient.Close()
+		database.RedisClient = nil
+	})
+	return mr
+}
+
+// enqueue 依次入队，每条消息之间间隔两毫秒，保证入队时间（毫秒）不同
+func enqueue(t *testing.T, userID string, messages ...Envelope) {
+	t.Helper()
+	for _, env := range messages {
+		env.UserID = userID
+		if env.Event == "" {
+			env.Event = EventMessage
+		}
+		if env.Data == nil {
+			env.Data = json.RawMessage(`{}`)
+		}
+		if err := Enqueue(context.Background(), env); err != nil {
+			t.Fatalf("Enqueue(%s) err = %v", env.MessageID, err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// pendingIDs 返回设备待确认消息的ID，按投递顺序排列
+func pendingIDs(t *testing.T, userID string, appID uint32) []string {
+	t.Helper()
+	envelopes, err := Pending(context.Background(), userID, appID)
+	if err != nil {
+		t.Fatalf("Pending() err = %v", err)
+	}
+	ids := make([]string, 0, len(envelopes))
+	for _, env := range envelopes {
+		ids = append(ids, env.MessageID)
+	}
+	return ids
+}
+
+func TestPendingPriorityOrder(t *testing.T) {
+	setupRedis(t)
+	enqueue(t, "1",
+		Envelope{MessageID: "m1"},
+		Envelope{MessageID: "p1", Priority: true},
+		Envelope{MessageID: "m2"},
+		Envelope{MessageID: "p2", Priority: true},
+		Envelope{MessageID: "m3"},
+	)
+
+	want := []string{"p1", "p2", "m1", "m2", "m3"}
+	if got := pendingIDs(t, "1", 101); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending() = %v, want %v", got, want)
+	}
+}
+
+func TestEnqueuePriorityMovesMessage(t *testing.T) {
+	setupRedis(t)
+	enqueue(t, "1",
+		Envelope{MessageID: "m1"},
+		Envelope{MessageID: "m2"},
+		Envelope{MessageID: "m1", Event: EventMention, Priority: true},
+	)
+
+	envelopes, err := Pending(context.Background(), "1", 101)
+	if err != nil {
+		t.Fatalf("Pending() err = %v", err)
+	}
+	if len(envelopes) != 2 {
+		t.Fatalf("Pending() 返回 %d 条，want 2", len(envelopes))
+	}
+	if envelopes[0].MessageID != "m1" || !envelopes[0].Priority || envelopes[0].Event != EventMention {
+		t.Errorf("Pending()[0] = %+v，want 高优先级的 m1", envelopes[0])
+	}
+	if envelopes[1].MessageID != "m2" {
+		t.Errorf("Pending()[1] = %s, want m2", envelopes[1].MessageID)
+	}
+}
+
+func TestAckSingleDevice(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	if err := RegisterDevice(ctx, "1", 101); err != nil {
+		t.Fatalf("RegisterDevice() err = %v", err)
+	}
+	enqueue(t, "1", Envelope{MessageID: "m1"}, Envelope{MessageID: "p1", Priority: true})
+
+	acked, err := Ack(ctx, "1", 101, "m1", "p1", "unknown")
+	if err != nil {
+		t.Fatalf("Ack() err = %v", err)
+	}
+	if acked != 2 {
+		t.Errorf("Ack() = %d, want 2", acked)
+	}
+	if got := pendingIDs(t, "1", 101); len(got) != 0 {
+		t.Errorf("Pending() = %v, want 空", got)
+	}
+	// 唯一的设备确认后消息从队列中移除
+	for _, key := range []string{pendingKey("1"), priorityKey("1"), pendingDataKey("1")} {
+		if mr.Exists(key) {
+			t.Errorf("%s 应当已被清空", key)
+		}
+	}
+
+	// 重复确认不再计数
+	if acked, _ := Ack(ctx, "1", 101, "m1"); acked != 0 {
+		t.Errorf("重复 Ack() = %d, want 0", acked)
+	}
+}
+
+func TestAckPerDevice(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	for _, appID := range []uint32{101, 102} {
+		if err := RegisterDevice(ctx, "1", appID); err != nil {
+			t.Fatalf("RegisterDevice(%d) err = %v", appID, err)
+		}
+	}
+	enqueue(t, "1", Envelope{MessageID: "m1"}, Envelope{MessageID: "m2"})
+
+	if acked, err := Ack(ctx, "1", 101, "m1"); err != nil || acked != 1 {
+		t.Fatalf("Ack(101) = %d, %v, want 1", acked, err)
+	}
+	// 一个设备确认后，其他设备仍会收到该消息
+	if got, want := pendingIDs(t, "1", 101), []string{"m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending(101) = %v, want %v", got, want)
+	}
+	if got, want := pendingIDs(t, "1", 102), []string{"m1", "m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending(102) = %v, want %v", got, want)
+	}
+	if mr.HGet(pendingDataKey("1"), "m1") == "" {
+		t.Fatal("只有一个设备确认时不应删除消息内容")
+	}
+
+	if acked, err := Ack(ctx, "1", 102, "m1"); err != nil || acked != 1 {
+		t.Fatalf("Ack(102) = %d, %v, want 1", acked, err)
+	}
+	// 全部设备确认后才从队列中移除
+	if mr.HGet(pendingDataKey("1"), "m1") != "" {
+		t.Error("全部设备确认后应当删除消息内容")
+	}
+	if got, want := pendingIDs(t, "1", 102), []string{"m2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending(102) = %v, want %v", got, want)
+	}
+	if ok, _ := mr.SIsMember(ackedKey("1", 101), "m1"); ok {
+		t.Error("全部设备确认后应当清除确认记录")
+	}
+}
+
+func TestRemoveIgnoresDevices(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+	for _, appID := range []uint32{101, 102} {
+		if err := RegisterDevice(ctx, "1", appID); err != nil {
+			t.Fatalf("RegisterDevice(%d) err = %v", appID, err)
+		}
+	}
+	enqueue(t, "1", Envelope{MessageID: "m1"}, Envelope{MessageID: "p1", Priority: true})
+
+	removed, err := Remove(ctx, "1", "m1", "p1")
+	if err != nil {
+		t.Fatalf("Remove() err = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Remove() = %d, want 2", removed)
+	}
+	for _, appID := range []uint32{101, 102} {
+		if got := pendingIDs(t, "1", appID); len(got) != 0 {
+			t.Errorf("Pending(%d) = %v, want 空", appID, got)
+		}
+	}
+}
+
+func TestPendingDropsExpired(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+	enqueue(t, "1", Envelope{MessageID: "old"}, Envelope{MessageID: "new"})
+
+	// 把 old 的入队时间改到保留时间之前
+	expired := float64(time.Now().Add(-pendingTTL() - time.Minute).UnixMilli())
+	database.RedisClient.ZAdd(ctx, pendingKey("1"), redis.Z{Score: expired, Member: "old"})
+
+	if got, want := pendingIDs(t, "1", 101), []string{"new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Pending() = %v, want %v", got, want)
+	}
+	if exists, _ := database.RedisClient.HExists(ctx, pendingDataKey("1"), "old").Result(); exists {
+		t.Error("过期消息的内容应当被删除")
+	}
+}
+
+func TestReplacePendingDataKeepsEnvelope(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+	enqueue(t, "1", Envelope{MessageID: "m1", Event: EventMention, Priority: true, Data: json.RawMessage(`{"content":"old"}`)})
+
+	replaced, err := ReplacePendingData(ctx, "1", "m1", map[string]string{"content": "new"})
+	if err != nil || !replaced {
+		t.Fatalf("ReplacePendingData() = %v, %v, want true", replaced, err)
+	}
+	envelopes, err := Pending(ctx, "1", 101)
+	if err != nil || len(envelopes) != 1 {
+		t.Fatalf("Pending() = %v, %v", envelopes, err)
+	}
+	env := envelopes[0]
+	if env.Event != EventMention || !env.Priority || string(env.Data) != `{"content":"new"}` {
+		t.Errorf("Pending()[0] = %+v，want 保留事件类型和优先级，只替换内容", env)
+	}
+
+	// 已确认的消息不再替换
+	if replaced, err := ReplacePendingData(ctx, "1", "missing", nil); err != nil || replaced {
+		t.Errorf("ReplacePendingData(missing) = %v, %v, want false", replaced, err)
+	}
+}
+
+func TestRerouteToOtherLiveNodes(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	redisCli := database.GetRedisClient()
+
+	// 用户登记在 a、b、c 三个节点上，c 已经失效
+	for _, node := range []string{"a", "b", "c"} {
+		if err := SetUserNode(ctx, "1", node, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, node := range []string{"a", "b"} {
+		if err := markAlive(ctx, redisCli, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := Envelope{UserID: "1", MessageID: "m1", Event: EventMessage, Data: json.RawMessage(`{}`)}
+	rerouted, err := Reroute(ctx, env, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rerouted {
+		t.Fatal("用户在其他存活节点上时应当转发")
+	}
+	for node, want := range map[string]int{"a": 0, "b": 1, "c": 0} {
+		if got := len(streamEntries(t, mr, node)); got != want {
+			t.Errorf("节点 %s 的流中有 %d 条信封, want %d", node, got, want)
+		}
+	}
+	entries := streamEntries(t, mr, "b")
+	var got Envelope
+	if err := json.Unmarshal([]byte(entries[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.MessageID != "m1" || got.UserID != "1" {
+		t.Errorf("转发的信封 = %+v, want m1 发给用户 1", got)
+	}
+
+	// 只登记在来源节点和失效节点上时不转发
+	for _, node := range []string{"a", "c"} {
+		if err := SetUserNode(ctx, "2", node, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rerouted, err = Reroute(ctx, Envelope{UserID: "2", MessageID: "m2"}, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rerouted {
+		t.Error("用户不在其他存活节点上时不应转发")
+	}
+}
+
+// streamEntries 返回节点流中保存的信封
+func streamEntries(t *testing.T, mr *miniredis.Miniredis, node string) []string {
+	t.Helper()
+	if !mr.Exists(StreamName(node)) {
+		return nil
+	}
+	entries, err := mr.Stream(StreamName(node))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		for i := 0; i+1 < len(entry.Values); i += 2 {
+			if entry.Values[i] == streamField {
+				values = append(values, entry.Values[i+1])
+			}
+		}
+	}
+	return values
+}
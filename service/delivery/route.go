@@ -0,0 +1,71 @@
+package delivery
+
+import (
+	"context"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// 用户可以同时在多个节点上保持连接（不同平台连到不同节点），user_nodes:<uid> 哈希表记录用户所在的全部节点
+// 字段为节点地址，值为该节点登记的过期时间（毫秒），每个节点在心跳时各自续期，节点崩溃后它的字段自然过期
+
+// userNodesKey 用户所在节点的哈希表
+func userNodesKey(userID string) string {
+	return "user_nodes:" + userID
+}
+
+// SetUserNode 登记用户在 node 上有连接，ttl 后未续期视为已离开该节点
+func SetUserNode(ctx context.Context, userID, node string, ttl time.Duration) error {
+	redisCli := database.GetRedisClient()
+	key := userNodesKey(userID)
+	pipe := redisCli.TxPipeline()
+	pipe.HSet(ctx, key, node, time.Now().Add(ttl).UnixMilli())
+	// 整个哈希表在最后一个节点过期后一并过期
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// clearUserNodeScript 移除用户在指定节点上的登记并清理已过期的节点，返回用户是否已经不在任何节点上
+var clearUserNodeScript = redis.NewScript(`
+redis.call('HDEL', KEYS[1], ARGV[1])
+local now = tonumber(ARGV[2])
+local nodes = redis.call('HGETALL', KEYS[1])
+local alive = 0
+for i = 1, #nodes, 2 do
+	if tonumber(nodes[i + 1]) > now then
+		alive = alive + 1
+	else
+		redis.call('HDEL', KEYS[1], nodes[i])
+	end
+end
+if alive == 0 then
+	return 1
+end
+return 0
+`)
+
+// ClearUserNode 移除用户在 node 上的登记，返回用户是否已经不在任何节点上
+func ClearUserNode(ctx context.Context, userID, node string) (bool, error) {
+	cleared, err := clearUserNodeScript.Run(ctx, database.GetRedisClient(),
+		[]string{userNodesKey(userID)}, node, time.Now().UnixMilli()).Int()
+	return cleared == 1, err
+}
+
+// UserNodes 查询用户当前所在的全部节点，用户离线时返回空
+func UserNodes(ctx context.Context, redisCli *redis.Client, userID string) ([]string, error) {
+	values, err := redisCli.HGetAll(ctx, userNodesKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	nodes := make([]string, 0, len(values))
+	for node, expireAt := range values {
+		if at, _ := strconv.ParseInt(expireAt, 10, 64); at > now {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
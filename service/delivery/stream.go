@@ -0,0 +1,288 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log"
+	"strings"
+	"time"
+)
+
+// 节点之间通过 Redis Stream 转发信封：每个节点一个流，流上有一个消费者组
+// 节点处理完信封后才确认，节点崩溃时未确认和未读取的条目由其他节点认领，不会丢失
+
+const (
+	streamsKey  = "message_streams" // 所有创建过流的节点集合
+	streamGroup = "delivery"        // 每个节点流上的消费者组
+	streamField = "envelope"        // 流条目中保存信封的字段
+
+	nodeAliveTTL  = 30 * time.Second // 节点存活标记的过期时间
+	claimIdle     = 30 * time.Second // 读取后超过该时间仍未确认的条目会被重新认领
+	streamBlock   = 5 * time.Second  // 阻塞读取流的超时时间
+	streamBatch   = 100              // 每次读取或认领的条目数
+	streamRetryIn = time.Second      // 读取流出错后的重试间隔
+
+	defaultStreamMaxLen    = 100000         // 每个节点流默认保留的最大条目数
+	defaultStreamRetention = 24 * time.Hour // 流中条目默认保留时间
+)
+
+// EnvelopeHandler 处理从节点流中读取的信封，返回后条目即被确认
+type EnvelopeHandler func(env Envelope)
+
+// StreamName 节点消息流名
+func StreamName(node string) string {
+	return fmt.Sprintf("message_stream%s", node)
+}
+
+// nodeAliveKey 节点存活标记，节点定期续期，过期说明节点已经失效
+func nodeAliveKey(node string) string {
+	return "node_alive:" + node
+}
+
+// streamMaxLen 每个节点流保留的最大条目数，可通过 message.streamMaxLen 配置
+func streamMaxLen() int64 {
+	if maxLen := viper.GetInt64("message.streamMaxLen"); maxLen > 0 {
+		return maxLen
+	}
+	return defaultStreamMaxLen
+}
+
+// streamRetention 流中条目的保留时间，可通过 message.streamRetention 配置
+func streamRetention() time.Duration {
+	if retention := viper.GetDuration("message.streamRetention"); retention > 0 {
+		return retention
+	}
+	return defaultStreamRetention
+}
+
+// publish 将信封追加到节点流，超过最大长度的旧条目会被近似裁剪
+func publish(ctx context.Context, cmd redis.Cmdable, node string, envMarshal []byte) error {
+	return cmd.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamName(node),
+		MaxLen: streamMaxLen(),
+		Approx: true,
+		Values: map[string]interface{}{streamField: envMarshal},
+	}).Err()
+}
+
+// isNodeAlive 判断节点是否仍在续期存活标记
+func isNodeAlive(ctx context.Context, redisCli *redis.Client, node string) (bool, error) {
+	count, err := redisCli.Exists(ctx, nodeAliveKey(node)).Result()
+	return count > 0, err
+}
+
+// markAlive 续期本节点的存活标记
+func markAlive(ctx context.Context, redisCli *redis.Client, node string) error {
+	return redisCli.Set(ctx, nodeAliveKey(node), time.Now().Unix(), nodeAliveTTL).Err()
+}
+
+// Retire 清除节点的存活标记，节点正常退出时调用，其他节点随即认领其消息流中的条目
+func Retire(ctx context.Context, node string) error {
+	return database.GetRedisClient().Del(ctx, nodeAliveKey(node)).Err()
+}
+
+// ensureGroup 创建节点流和消费者组，并登记到节点集合中供其他节点在本节点失效后认领
+func ensureGroup(ctx context.Context, redisCli *redis.Client, node string) error {
+	err := redisCli.XGroupCreateMkStream(ctx, StreamName(node), streamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return redisCli.SAdd(ctx, streamsKey, node).Err()
+}
+
+// Consume 以消费者组读取本节点的流并交给 handle 处理，处理完成后确认，ctx 取消时返回
+func Consume(ctx context.Context, node string, handle EnvelopeHandler) {
+	redisCli := database.GetRedisClient()
+	stream := StreamName(node)
+	groupReady := false
+	for ctx.Err() == nil {
+		if !groupReady {
+			if err := markAlive(ctx, redisCli, node); err != nil {
+				log.Printf("写入节点 %s 存活标记失败: %v", node, err)
+			}
+			if err := ensureGroup(ctx, redisCli, node); err != nil {
+				log.Printf("创建节点流 %s 失败: %v", stream, err)
+				time.Sleep(streamRetryIn)
+				continue
+			}
+			groupReady = true
+		}
+
+		streams, err := redisCli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamGroup,
+			Consumer: node,
+			Streams:  []string{stream, ">"},
+			Count:    streamBatch,
+			Block:    streamBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			log.Printf("读取节点流 %s 失败: %v", stream, err)
+			// 流或消费者组被删除后重新创建
+			groupReady = !strings.HasPrefix(err.Error(), "NOGROUP")
+			time.Sleep(streamRetryIn)
+			continue
+		}
+		for _, s := range streams {
+			handleEntries(ctx, redisCli, stream, s.Messages, handle)
+		}
+	}
+}
+
+// handleEntries 逐条解析并处理流条目，然后一并确认；无法解析的条目同样确认，避免反复认领
+func handleEntries(ctx context.Context, redisCli *redis.Client, stream string, messages []redis.XMessage, handle EnvelopeHandler) {
+	if len(messages) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		raw, ok := message.Values[streamField].(string)
+		if !ok {
+			// 条目已被裁剪
+			continue
+		}
+		var env Envelope
+		if err := json.Unmarshal([]byte(raw), &env); err != nil {
+			log.Printf("解析节点流 %s 中的信封 %s 失败: %v", stream, message.ID, err)
+			continue
+		}
+		handle(env)
+	}
+	if err := redisCli.XAck(ctx, stream, streamGroup, ids...).Err(); err != nil {
+		log.Printf("确认节点流 %s 条目失败: %v", stream, err)
+	}
+}
+
+// Maintain 定期续期本节点的存活标记，认领本节点和已失效节点流中的条目，并裁剪过期条目，ctx 取消时返回
+func Maintain(ctx context.Context, node string, handle EnvelopeHandler) {
+	redisCli := database.GetRedisClient()
+	ticker := time.NewTicker(nodeAliveTTL / 3)
+	defer ticker.Stop()
+	for {
+		if err := markAlive(ctx, redisCli, node); err != nil {
+			log.Printf("写入节点 %s 存活标记失败: %v", node, err)
+		}
+		recoverStreams(ctx, redisCli, node, handle)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverStreams 处理本节点读取后长时间未确认的条目；对已失效的节点，先读取其流中尚未读取的条目，
+// 再认领其读取后未确认的条目，处理完毕且条目全部过期后删除该节点的流
+func recoverStreams(ctx context.Context, redisCli *redis.Client, node string, handle EnvelopeHandler) {
+	nodes, err := redisCli.SMembers(ctx, streamsKey).Result()
+	if err != nil {
+		log.Printf("获取节点流列表失败: %v", err)
+		return
+	}
+	for _, other := range nodes {
+		stream := StreamName(other)
+		if other != node {
+			alive, err := isNodeAlive(ctx, redisCli, other)
+			if err != nil {
+				log.Printf("查询节点 %s 存活状态失败: %v", other, err)
+				continue
+			}
+			if alive {
+				continue
+			}
+			drainStream(ctx, redisCli, stream, node, handle)
+		}
+		claimStream(ctx, redisCli, stream, node, handle)
+		trimStream(ctx, redisCli, stream)
+
+		if other != node {
+			removeEmptyStream(ctx, redisCli, other)
+		}
+	}
+}
+
+// drainStream 以本节点为消费者读取失效节点流中尚未被读取的条目
+func drainStream(ctx context.Context, redisCli *redis.Client, stream, consumer string, handle EnvelopeHandler) {
+	for ctx.Err() == nil {
+		streams, err := redisCli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    streamGroup,
+			Consumer: consumer,
+			Streams:  []string{stream, ">"},
+			Count:    streamBatch,
+			Block:    -1,
+		}).Result()
+		if err == redis.Nil {
+			return
+		}
+		if err != nil {
+			log.Printf("读取失效节点流 %s 失败: %v", stream, err)
+			return
+		}
+		read := 0
+		for _, s := range streams {
+			read += len(s.Messages)
+			handleEntries(ctx, redisCli, stream, s.Messages, handle)
+		}
+		if read == 0 {
+			return
+		}
+	}
+}
+
+// claimStream 使用 XAUTOCLAIM 认领读取后超过 claimIdle 仍未确认的条目并处理
+func claimStream(ctx context.Context, redisCli *redis.Client, stream, consumer string, handle EnvelopeHandler) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		messages, next, err := redisCli.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    streamGroup,
+			Consumer: consumer,
+			MinIdle:  claimIdle,
+			Start:    start,
+			Count:    streamBatch,
+		}).Result()
+		if err != nil {
+			log.Printf("认领节点流 %s 条目失败: %v", stream, err)
+			return
+		}
+		if len(messages) > 0 {
+			log.Printf("从节点流 %s 认领 %d 条未确认的条目", stream, len(messages))
+		}
+		handleEntries(ctx, redisCli, stream, messages, handle)
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// trimStream 裁剪超过保留时间的条目，必须送达的消息仍保存在待确认队列中
+func trimStream(ctx context.Context, redisCli *redis.Client, stream string) {
+	minID := fmt.Sprintf("%d-0", time.Now().Add(-streamRetention()).UnixMilli())
+	if err := redisCli.XTrimMinIDApprox(ctx, stream, minID, 0).Err(); err != nil {
+		log.Printf("裁剪节点流 %s 失败: %v", stream, err)
+	}
+}
+
+// removeEmptyStream 失效节点的流中已经没有条目时删除该流
+func removeEmptyStream(ctx context.Context, redisCli *redis.Client, node string) {
+	stream := StreamName(node)
+	length, err := redisCli.XLen(ctx, stream).Result()
+	if err != nil || length > 0 {
+		return
+	}
+	pipe := redisCli.TxPipeline()
+	pipe.Del(ctx, stream)
+	pipe.SRem(ctx, streamsKey, node)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("删除失效节点流 %s 失败: %v", stream, err)
+	}
+}
@@ -0,0 +1,129 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// publishEnvelopes 依次将信封追加到节点流
+func publishEnvelopes(t *testing.T, node string, messageIDs ...string) {
+	t.Helper()
+	for _, messageID := range messageIDs {
+		envMarshal, err := json.Marshal(Envelope{UserID: "1", MessageID: messageID, Event: EventMessage})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := publish(context.Background(), database.GetRedisClient(), node, envMarshal); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// collect 记录处理过的信封的消息ID
+func collect(handled *[]string) EnvelopeHandler {
+	return func(env Envelope) {
+		*handled = append(*handled, env.MessageID)
+	}
+}
+
+func TestRecoverStreamsTakesOverDeadNode(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	redisCli := database.GetRedisClient()
+
+	// 失效节点 dead 读取了 m1、m2 但没有确认就崩溃了，m3 还没有被读取
+	if err := ensureGroup(ctx, redisCli, "dead"); err != nil {
+		t.Fatal(err)
+	}
+	publishEnvelopes(t, "dead", "m1", "m2")
+	if _, err := redisCli.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: streamGroup, Consumer: "dead", Streams: []string{StreamName("dead"), ">"}, Block: -1,
+	}).Result(); err != nil {
+		t.Fatal(err)
+	}
+	publishEnvelopes(t, "dead", "m3")
+	// 无法解析的条目同样确认，不交给处理函数
+	if err := redisCli.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamName("dead"), Values: map[string]interface{}{streamField: "not json"},
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 存活节点 other 的流不应被接管
+	if err := ensureGroup(ctx, redisCli, "other"); err != nil {
+		t.Fatal(err)
+	}
+	publishEnvelopes(t, "other", "o1")
+	for _, node := range []string{"live", "other"} {
+		if err := markAlive(ctx, redisCli, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ensureGroup(ctx, redisCli, "live"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 让 dead 读取后未确认的条目超过 claimIdle
+	mr.SetTime(time.Now().Add(2 * claimIdle))
+
+	var handled []string
+	recoverStreams(ctx, redisCli, "live", collect(&handled))
+	sort.Strings(handled)
+	if want := []string{"m1", "m2", "m3"}; !reflect.DeepEqual(handled, want) {
+		t.Fatalf("处理的信封 = %v, want %v", handled, want)
+	}
+
+	pending, err := redisCli.XPending(ctx, StreamName("dead"), streamGroup).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("失效节点流中还有 %d 条未确认的条目", pending.Count)
+	}
+	// 条目尚未过期，流保留到裁剪之后
+	if !mr.Exists(StreamName("dead")) {
+		t.Error("条目过期前不应删除失效节点的流")
+	}
+
+	// 再次恢复不会重复处理已确认的条目
+	handled = nil
+	recoverStreams(ctx, redisCli, "live", collect(&handled))
+	if len(handled) != 0 {
+		t.Errorf("重复处理了 %v", handled)
+	}
+}
+
+func TestRecoverStreamsRemovesExpiredDeadStream(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+	redisCli := database.GetRedisClient()
+	viper.Set("message.streamRetention", time.Millisecond)
+	t.Cleanup(func() { viper.Set("message.streamRetention", nil) })
+
+	if err := ensureGroup(ctx, redisCli, "dead"); err != nil {
+		t.Fatal(err)
+	}
+	publishEnvelopes(t, "dead", "m1")
+	time.Sleep(10 * time.Millisecond)
+
+	// 条目处理完并全部过期后删除流，并从节点集合中移除
+	var handled []string
+	recoverStreams(ctx, redisCli, "live", collect(&handled))
+	if len(handled) != 1 || handled[0] != "m1" {
+		t.Fatalf("处理的信封 = %v, want [m1]", handled)
+	}
+	if mr.Exists(StreamName("dead")) {
+		t.Error("失效节点的流应当被删除")
+	}
+	if ok, _ := mr.SIsMember(streamsKey, "dead"); ok {
+		t.Error("失效节点应当从节点集合中移除")
+	}
+}
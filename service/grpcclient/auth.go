@@ -0,0 +1,46 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 节点之间的 gRPC 调用通过 websocket.rpcSecret 配置的共享密钥认证，所有节点必须配置相同的密钥
+
+const secretMetadataKey = "x-node-secret" // 请求元数据中携带共享密钥的键
+
+// Secret 节点之间共享的密钥
+func Secret() string {
+	return viper.GetString("websocket.rpcSecret")
+}
+
+// secretCredentials 在每次调用的元数据中附带共享密钥
+type secretCredentials struct {
+	secret string
+}
+
+func (c secretCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{secretMetadataKey: c.secret}, nil
+}
+
+// RequireTransportSecurity 节点之间走内网明文连接
+func (c secretCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// AuthInterceptor 服务端拦截器，拒绝没有携带正确共享密钥的调用
+func AuthInterceptor(secret string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(secretMetadataKey)
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(secret)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "节点密钥错误")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,64 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	interceptor := AuthInterceptor("s3cret")
+	info := &grpc.UnaryServerInfo{FullMethod: "/protobuf.Delivery/Deliver"}
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		passed bool
+	}{
+		{"正确的密钥", metadata.Pairs(secretMetadataKey, "s3cret"), true},
+		{"错误的密钥", metadata.Pairs(secretMetadataKey, "wrong"), false},
+		{"密钥前缀", metadata.Pairs(secretMetadataKey, "s3c"), false},
+		{"多个密钥", metadata.Pairs(secretMetadataKey, "s3cret", secretMetadataKey, "s3cret"), false},
+		{"没有密钥", metadata.Pairs("other", "s3cret"), false},
+		{"没有元数据", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			called := false
+			resp, err := interceptor(ctx, "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return "resp", nil
+			})
+			if called != tt.passed {
+				t.Fatalf("handler called = %v, want %v", called, tt.passed)
+			}
+			if tt.passed {
+				if err != nil || resp != "resp" {
+					t.Errorf("interceptor = %v, %v, want resp, nil", resp, err)
+				}
+				return
+			}
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("错误码 = %v, want %v", status.Code(err), codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestSecretCredentialsAttachSecret(t *testing.T) {
+	md, err := secretCredentials{secret: "s3cret"}.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md[secretMetadataKey] != "s3cret" {
+		t.Errorf("元数据 = %v, want %s=s3cret", md, secretMetadataKey)
+	}
+}
@@ -0,0 +1,115 @@
+// Package grpcclient 调用其他节点的 gRPC 投递服务，连接按节点地址复用
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"github.com/helpleness/IMChatAdmin/service/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sync"
+	"time"
+)
+
+const callTimeout = 2 * time.Second // 单次调用的超时时间，超时后调用方走 Redis 投递
+
+var errNoSecret = errors.New("未配置 websocket.rpcSecret，节点之间不使用 gRPC 投递")
+
+var (
+	clients     = make(map[string]protobuf.DeliveryServerClient) // 节点地址 -> 客户端
+	clientsLock sync.Mutex
+)
+
+// getClient 获取节点的客户端，连接在第一次调用时建立
+func getClient(node string) (protobuf.DeliveryServerClient, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+	if client, ok := clients[node]; ok {
+		return client, nil
+	}
+	secret := Secret()
+	if secret == "" {
+		return nil, errNoSecret
+	}
+	conn, err := grpc.NewClient(node,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(secretCredentials{secret: secret}))
+	if err != nil {
+		return nil, err
+	}
+	client := protobuf.NewDeliveryServerClient(conn)
+	clients[node] = client
+	return client, nil
+}
+
+// DeliverToUser 将信封推送到用户在指定节点上的连接，返回用户在该节点上是否有连接
+func DeliverToUser(ctx context.Context, node, userID string, envelope []byte) (bool, error) {
+	client, err := getClient(node)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	rsp, err := client.DeliverToUser(ctx, &protobuf.DeliverToUserReq{UserId: userID, Envelope: envelope})
+	if err != nil {
+		return false, err
+	}
+	return rsp.Delivered, nil
+}
+
+// KickUser 关闭用户在指定节点上的连接，appID 为0时关闭全部平台上的连接，返回关闭的连接数
+func KickUser(ctx context.Context, node string, userID uint, appID uint32) (int, error) {
+	client, err := getClient(node)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	rsp, err := client.KickUser(ctx, &protobuf.KickUserReq{UserId: uint64(userID), AppId: appID})
+	if err != nil {
+		return 0, err
+	}
+	return int(rsp.Kicked), nil
+}
+
+// QueryOnline 查询一组用户中哪些在指定节点上有连接
+func QueryOnline(ctx context.Context, node string, userIDs []uint) ([]uint, error) {
+	client, err := getClient(node)
+	if err != nil {
+		return nil, err
+	}
+	req := &protobuf.QueryOnlineReq{UserIds: make([]uint64, len(userIDs))}
+	for i, userID := range userIDs {
+		req.UserIds[i] = uint64(userID)
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	rsp, err := client.QueryOnline(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	online := make([]uint, len(rsp.OnlineUserIds))
+	for i, userID := range rsp.OnlineUserIds {
+		online[i] = uint(userID)
+	}
+	return online, nil
+}
+
+// BroadcastToGroup 将同一个信封推送给一组用户在指定节点上的连接，返回在该节点上有连接的用户
+func BroadcastToGroup(ctx context.Context, node string, groupID int, userIDs []string, envelope []byte) ([]string, error) {
+	client, err := getClient(node)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	rsp, err := client.BroadcastToGroup(ctx, &protobuf.BroadcastToGroupReq{
+		GroupId:  int64(groupID),
+		UserIds:  userIDs,
+		Envelope: envelope,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp.DeliveredUserIds, nil
+}
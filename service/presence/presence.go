@@ -0,0 +1,216 @@
+// Package presence 维护用户的在线状态和最后在线时间
+// 用户可以同时连到多个节点，每个节点在 presence:<uid> 哈希表中各自写入用户在本节点上的状态和过期时间，由心跳续期；
+// 用户的状态由所有未过期的节点汇总得出，节点崩溃后它写入的状态会在超时后自动失效
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/model"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatusOnline  = "online"  // 在线
+	StatusAway    = "away"    // 离开，连接仍然保持
+	StatusOffline = "offline" // 离线
+
+	defaultHeartbeatTimeout = 90 * time.Second // 默认心跳超时时间
+)
+
+// Presence 用户的在线状态
+type Presence struct {
+	UserID   uint   `json:"user_id"`
+	Status   string `json:"status"`    // online、away 或 offline
+	LastSeen int64  `json:"last_seen"` // 最后在线时间（Unix时间戳），从未上线时为0
+}
+
+// Timeout 心跳超时时间，超过该时间没有心跳的连接会被关闭，在线状态也会随之过期
+func Timeout() time.Duration {
+	if timeout := viper.GetDuration("websocket.heartbeatTimeout"); timeout > 0 {
+		return timeout
+	}
+	return defaultHeartbeatTimeout
+}
+
+// ValidStatus 判断是否为客户端可以上报的状态
+func ValidStatus(status string) bool {
+	return status == StatusOnline || status == StatusAway
+}
+
+// statusKey 用户在各节点上状态的哈希表，字段为节点地址，值为 "状态:过期时间（毫秒）"
+func statusKey(userID uint) string {
+	return fmt.Sprintf("presence:%d", userID)
+}
+
+func lastSeenKey(userID uint) string {
+	return fmt.Sprintf("last_seen:%d", userID)
+}
+
+// aggregate 汇总用户在各节点上未过期的状态：任一节点在线即为在线，都是离开时为离开，没有未过期的节点时为离线
+func aggregate(values map[string]string, now int64) string {
+	status := StatusOffline
+	for _, value := range values {
+		nodeStatus, expireAt, ok := strings.Cut(value, ":")
+		if at, _ := strconv.ParseInt(expireAt, 10, 64); !ok || at <= now {
+			continue
+		}
+		if nodeStatus == StatusOnline {
+			return StatusOnline
+		}
+		status = StatusAway
+	}
+	return status
+}
+
+// setScript 写入或移除节点上报的状态，清理已过期的节点，返回修改前后的汇总状态
+// 汇总规则与 aggregate 相同；用户在线期间和刚变为离线时更新最后在线时间
+// KEYS: 状态哈希表、最后在线时间；ARGV: 节点、状态（为空时移除）、当前时间（毫秒）、过期时间（毫秒）、过期秒数、当前时间（秒）
+var setScript = redis.NewScript(`
+local now = tonumber(ARGV[3])
+local function aggregate()
+	local status = 'offline'
+	local values = redis.call('HGETALL', KEYS[1])
+	for i = 1, #values, 2 do
+		local sep = string.find(values[i + 1], ':', 1, true)
+		local expireAt = sep and tonumber(string.sub(values[i + 1], sep + 1)) or 0
+		if expireAt > now then
+			local nodeStatus = string.sub(values[i + 1], 1, sep - 1)
+			if nodeStatus == 'online' then
+				return 'online'
+			end
+			status = 'away'
+		else
+			redis.call('HDEL', KEYS[1], values[i])
+		end
+	end
+	return status
+end
+
+local before = aggregate()
+if ARGV[2] == '' then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+else
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2] .. ':' .. ARGV[4])
+	redis.call('EXPIRE', KEYS[1], ARGV[5])
+end
+local after = aggregate()
+if before ~= 'offline' or after ~= 'offline' then
+	redis.call('SET', KEYS[2], ARGV[6])
+end
+return {before, after}
+`)
+
+// set 写入（status 非空）或移除 node 上的状态，返回汇总状态是否发生了变化以及变化后的汇总状态
+func set(ctx context.Context, userID uint, node, status string) (bool, string, error) {
+	now := time.Now()
+	timeout := Timeout()
+	result, err := setScript.Run(ctx, database.GetRedisClient(),
+		[]string{statusKey(userID), lastSeenKey(userID)},
+		node, status, now.UnixMilli(), now.Add(timeout).UnixMilli(), int64(timeout/time.Second)+1, now.Unix()).StringSlice()
+	if err != nil {
+		return false, "", err
+	}
+	if len(result) != 2 {
+		return false, "", fmt.Errorf("在线状态脚本返回了 %d 个值", len(result))
+	}
+	return result[0] != result[1], result[1], nil
+}
+
+// Set 写入用户在 node 上的状态并续期，返回用户的汇总状态是否发生了变化以及变化后的汇总状态
+func Set(ctx context.Context, userID uint, node, status string) (bool, string, error) {
+	return set(ctx, userID, node, status)
+}
+
+// SetOffline 移除用户在 node 上的状态，返回用户的汇总状态是否发生了变化以及变化后的汇总状态
+// 用户在其他节点上仍有连接时汇总状态可能保持不变
+func SetOffline(ctx context.Context, userID uint, node string) (bool, string, error) {
+	return set(ctx, userID, node, "")
+}
+
+// Get 批量获取用户的汇总状态，所有节点上的状态都已过期的用户视为离线
+func Get(ctx context.Context, userIDs []uint) ([]Presence, error) {
+	if len(userIDs) == 0 {
+		return []Presence{}, nil
+	}
+	redisCli := database.GetRedisClient()
+	pipe := redisCli.Pipeline()
+	statusCmds := make([]*redis.MapStringStringCmd, len(userIDs))
+	lastSeenKeys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		statusCmds[i] = pipe.HGetAll(ctx, statusKey(userID))
+		lastSeenKeys[i] = lastSeenKey(userID)
+	}
+	lastSeenCmd := pipe.MGet(ctx, lastSeenKeys...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	lastSeens := lastSeenCmd.Val()
+	presences := make([]Presence, len(userIDs))
+	for i, userID := range userIDs {
+		presences[i] = Presence{UserID: userID, Status: aggregate(statusCmds[i].Val(), now)}
+		if lastSeen, ok := lastSeens[i].(string); ok {
+			presences[i].LastSeen, _ = strconv.ParseInt(lastSeen, 10, 64)
+		}
+	}
+	return presences, nil
+}
+
+// NotifyFriends 以 ONLINE_STATUS 消息向用户在线的好友推送状态变化，不保存离线副本
+func NotifyFriends(ctx context.Context, userID uint, status string) {
+	var friendships []model.Friends
+	if err := database.GetDB().WithContext(ctx).
+		Where("user_id = ? OR friend_id = ?", int(userID), int(userID)).
+		Find(&friendships).Error; err != nil {
+		log.Printf("查询用户 %d 好友失败: %v", userID, err)
+		return
+	}
+
+	now := time.Now().Unix()
+	content, err := json.Marshal(Presence{UserID: userID, Status: status, LastSeen: now})
+	if err != nil {
+		log.Printf("序列化用户 %d 在线状态失败: %v", userID, err)
+		return
+	}
+	userIDStr := strconv.Itoa(int(userID))
+	seen := make(map[int]bool, len(friendships))
+	for _, friendship := range friendships {
+		friendID := friendship.UserID
+		if friendID == int(userID) {
+			friendID = friendship.FriendID
+		}
+		if seen[friendID] {
+			continue
+		}
+		seen[friendID] = true
+
+		friendIDStr := strconv.Itoa(friendID)
+		msg := model.MyMessage{
+			MessageID:  uuid.NewString(),
+			UserFrom:   userIDStr,
+			SendTarget: friendIDStr,
+			Content:    string(content),
+			Type:       model.ONLINE_STATUS,
+			ChatType:   model.SINGLE_CHAT,
+			SendTime:   now,
+		}
+		env, err := delivery.NewEnvelope(friendIDStr, msg.MessageID, delivery.EventMessage, msg)
+		if err == nil {
+			err = delivery.Notify(ctx, env)
+		}
+		if err != nil {
+			log.Printf("推送用户 %d 在线状态到好友 %d 失败: %v", userID, friendID, err)
+		}
+	}
+}
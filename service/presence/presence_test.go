@@ -0,0 +1,97 @@
+package presence
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// setupRedis 用 miniredis 替换全局 Redis 客户端
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.RedisClient.Close()
+		database.RedisClient = nil
+	})
+	return mr
+}
+
+func getStatus(t *testing.T, userID uint) Presence {
+	t.Helper()
+	presences, err := Get(context.Background(), []uint{userID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return presences[0]
+}
+
+func TestPresenceAggregatesAcrossNodes(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+
+	steps := []struct {
+		name    string
+		apply   func() (bool, string, error)
+		changed bool
+		status  string
+	}{
+		{"节点A上线", func() (bool, string, error) { return Set(ctx, 1, "a", StatusOnline) }, true, StatusOnline},
+		{"节点B离开", func() (bool, string, error) { return Set(ctx, 1, "b", StatusAway) }, false, StatusOnline},
+		{"节点A断开", func() (bool, string, error) { return SetOffline(ctx, 1, "a") }, true, StatusAway},
+		{"节点A重复断开", func() (bool, string, error) { return SetOffline(ctx, 1, "a") }, false, StatusAway},
+		{"节点B断开", func() (bool, string, error) { return SetOffline(ctx, 1, "b") }, true, StatusOffline},
+	}
+	for _, step := range steps {
+		changed, status, err := step.apply()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if changed != step.changed || status != step.status {
+			t.Errorf("%s: changed = %v, status = %s, want %v, %s", step.name, changed, status, step.changed, step.status)
+		}
+		if got := getStatus(t, 1); got.Status != step.status {
+			t.Errorf("%s: Get = %s, want %s", step.name, got.Status, step.status)
+		}
+	}
+	if got := getStatus(t, 1); got.LastSeen == 0 {
+		t.Error("离线后应当记录最后在线时间")
+	}
+	if got := getStatus(t, 2); got.Status != StatusOffline || got.LastSeen != 0 {
+		t.Errorf("从未上线的用户 = %+v, want offline 且没有最后在线时间", got)
+	}
+}
+
+func TestPresenceExpiresStaleNode(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+	viper.Set("websocket.heartbeatTimeout", 50*time.Millisecond)
+	t.Cleanup(func() { viper.Set("websocket.heartbeatTimeout", nil) })
+
+	if _, _, err := Set(ctx, 1, "a", StatusOnline); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("websocket.heartbeatTimeout", time.Minute)
+	if _, _, err := Set(ctx, 1, "b", StatusAway); err != nil {
+		t.Fatal(err)
+	}
+
+	// 节点A崩溃后不再续期，超时后汇总状态只取决于节点B
+	time.Sleep(100 * time.Millisecond)
+	if got := getStatus(t, 1); got.Status != StatusAway {
+		t.Errorf("节点A过期后 Get = %s, want %s", got.Status, StatusAway)
+	}
+	changed, status, err := SetOffline(ctx, 1, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || status != StatusOffline {
+		t.Errorf("SetOffline = %v, %s, want true, %s", changed, status, StatusOffline)
+	}
+}
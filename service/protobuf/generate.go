@@ -0,0 +1,4 @@
+// Package protobuf 连接节点之间的 gRPC 投递服务，接口定义见 delivery.proto
+package protobuf
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative delivery.proto
@@ -0,0 +1,152 @@
+// Package registry WebSocket 连接节点注册表
+// 每个节点定期把自己的地址和连接数写入带过期时间的 Redis 键，停止续期的节点会自动从注册表中消失
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/database"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	nodesKey        = "ws_nodes"       // 所有注册过的节点地址集合
+	NodeTTL         = 30 * time.Second // 节点注册信息的过期时间
+	RefreshInterval = NodeTTL / 3      // 节点续期间隔
+	virtualNodes    = 100              // 一致性哈希环上每个节点的虚拟节点数
+
+	PlacementLeastLoaded = "least" // 分配连接数最少的节点
+	PlacementHash        = "hash"  // 按用户ID一致性哈希分配节点
+)
+
+var ErrNoNode = errors.New("没有可用的连接节点")
+
+// Node 连接节点的注册信息
+type Node struct {
+	Addr        string `json:"addr"`        // ip:rpcPort，与 user_nodes:<uid> 中登记的节点地址一致
+	IP          string `json:"ip"`          // 节点地址
+	Port        string `json:"port"`        // WebSocket 端口
+	RPCPort     string `json:"rpc_port"`    // gRPC 投递服务端口
+	Connections int    `json:"connections"` // 当前连接数
+	UpdatedAt   int64  `json:"updated_at"`  // 最后续期时间（Unix时间戳）
+}
+
+func nodeKey(addr string) string {
+	return "ws_node:" + addr
+}
+
+// Register 写入或续期节点注册信息
+func Register(ctx context.Context, node Node) error {
+	node.UpdatedAt = time.Now().Unix()
+	nodeMarshal, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	pipe := database.GetRedisClient().TxPipeline()
+	pipe.Set(ctx, nodeKey(node.Addr), nodeMarshal, NodeTTL)
+	pipe.SAdd(ctx, nodesKey, node.Addr)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Unregister 注销节点，节点正常退出时调用
+func Unregister(ctx context.Context, addr string) error {
+	pipe := database.GetRedisClient().TxPipeline()
+	pipe.Del(ctx, nodeKey(addr))
+	pipe.SRem(ctx, nodesKey, addr)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Nodes 获取所有健康的节点，注册信息已过期的节点会从集合中移除
+func Nodes(ctx context.Context) ([]Node, error) {
+	redisCli := database.GetRedisClient()
+	addrs, err := redisCli.SMembers(ctx, nodesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(addrs))
+	for i, addr := range addrs {
+		keys[i] = nodeKey(addr)
+	}
+	values, err := redisCli.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, 0, len(addrs))
+	var expired []interface{}
+	for i, value := range values {
+		nodeJSON, ok := value.(string)
+		if !ok {
+			expired = append(expired, addrs[i])
+			continue
+		}
+		var node Node
+		if err := json.Unmarshal([]byte(nodeJSON), &node); err != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	if len(expired) > 0 {
+		redisCli.SRem(ctx, nodesKey, expired...)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Addr < nodes[j].Addr })
+	return nodes, nil
+}
+
+// Pick 为用户选择连接节点，placement 为 PlacementHash 时按用户ID一致性哈希，否则选择连接数最少的节点
+func Pick(ctx context.Context, userID uint, placement string) (Node, error) {
+	nodes, err := Nodes(ctx)
+	if err != nil {
+		return Node{}, err
+	}
+	if len(nodes) == 0 {
+		return Node{}, ErrNoNode
+	}
+	if placement == PlacementHash {
+		return pickByHash(nodes, userID), nil
+	}
+	return pickLeastLoaded(nodes), nil
+}
+
+// pickLeastLoaded 选择连接数最少的节点，连接数相同时按地址排序取第一个
+func pickLeastLoaded(nodes []Node) Node {
+	best := nodes[0]
+	for _, node := range nodes[1:] {
+		if node.Connections < best.Connections {
+			best = node
+		}
+	}
+	return best
+}
+
+// pickByHash 在一致性哈希环上选择用户对应的节点，节点增减时只有少部分用户会换到其他节点
+func pickByHash(nodes []Node, userID uint) Node {
+	type point struct {
+		hash uint32
+		node int
+	}
+	ring := make([]point, 0, len(nodes)*virtualNodes)
+	for i, node := range nodes {
+		for v := 0; v < virtualNodes; v++ {
+			ring = append(ring, point{hash: crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s#%d", node.Addr, v))), node: i})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	hash := crc32.ChecksumIEEE([]byte(strconv.FormatUint(uint64(userID), 10)))
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= hash })
+	if i == len(ring) {
+		i = 0
+	}
+	return nodes[ring[i].node]
+}
@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupRedis 用 miniredis 替换全局 Redis 客户端
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.RedisClient.Close()
+		database.RedisClient = nil
+	})
+	return mr
+}
+
+func TestPickLeastLoaded(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []Node
+		want  string
+	}{
+		{"单个节点", []Node{{Addr: "a", Connections: 5}}, "a"},
+		{"连接数最少", []Node{{Addr: "a", Connections: 5}, {Addr: "b", Connections: 1}, {Addr: "c", Connections: 3}}, "b"},
+		{"连接数相同取第一个", []Node{{Addr: "a", Connections: 2}, {Addr: "b", Connections: 2}}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickLeastLoaded(tt.nodes); got.Addr != tt.want {
+				t.Errorf("pickLeastLoaded = %s, want %s", got.Addr, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickByHash(t *testing.T) {
+	nodes := []Node{{Addr: "10.0.0.1:9000"}, {Addr: "10.0.0.2:9000"}, {Addr: "10.0.0.3:9000"}}
+
+	// 同一用户总是分配到同一个节点，与节点在列表中的顺序无关
+	reversed := []Node{nodes[2], nodes[1], nodes[0]}
+	for userID := uint(1); userID <= 100; userID++ {
+		if a, b := pickByHash(nodes, userID), pickByHash(reversed, userID); a.Addr != b.Addr {
+			t.Fatalf("用户 %d 分配到 %s 和 %s", userID, a.Addr, b.Addr)
+		}
+	}
+
+	// 用户分散到所有节点
+	const users = 3000
+	counts := make(map[string]int)
+	before := make(map[uint]string, users)
+	for userID := uint(1); userID <= users; userID++ {
+		addr := pickByHash(nodes, userID).Addr
+		counts[addr]++
+		before[userID] = addr
+	}
+	for _, node := range nodes {
+		if counts[node.Addr] < users/10 {
+			t.Errorf("节点 %s 只分配到 %d 个用户", node.Addr, counts[node.Addr])
+		}
+	}
+
+	// 移除一个节点时，只有原来在该节点上的用户换到其他节点
+	remaining := nodes[:2]
+	for userID := uint(1); userID <= users; userID++ {
+		addr := pickByHash(remaining, userID).Addr
+		if before[userID] != nodes[2].Addr && addr != before[userID] {
+			t.Fatalf("用户 %d 从 %s 换到了 %s", userID, before[userID], addr)
+		}
+	}
+}
+
+func TestNodesDropsExpired(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+
+	for _, node := range []Node{{Addr: "b", Connections: 1}, {Addr: "a", Connections: 2}} {
+		if err := Register(ctx, node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes, err := Nodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].Addr != "a" || nodes[1].Addr != "b" || nodes[0].UpdatedAt == 0 {
+		t.Fatalf("Nodes = %+v, want a、b 按地址排序", nodes)
+	}
+
+	// a 停止续期，注册信息过期后从集合中移除
+	mr.FastForward(NodeTTL)
+	if err := Register(ctx, Node{Addr: "b", Connections: 1}); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err = Nodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Addr != "b" {
+		t.Fatalf("Nodes = %+v, want 只剩 b", nodes)
+	}
+	if ok, _ := mr.SIsMember(nodesKey, "a"); ok {
+		t.Error("过期的节点应当从集合中移除")
+	}
+
+	if err := Unregister(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Pick(ctx, 1, PlacementHash); err != ErrNoNode {
+		t.Errorf("Pick err = %v, want ErrNoNode", err)
+	}
+}
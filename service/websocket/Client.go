@@ -3,31 +3,82 @@ package websocket
 import (
 	"fmt"
 	"github.com/gorilla/websocket"
+	"github.com/helpleness/IMChatAdmin/service/presence"
+	"github.com/spf13/viper"
 	"runtime/debug"
+	"sync"
 	"time"
 )
 
+const (
+	defaultSendBuffer = 256              // 默认发送缓冲区大小
+	writeWait         = 10 * time.Second // 单次写入的超时时间
+)
+
+// sendGrace 发送缓冲区已满时等待写协程腾出空间的时间，超过后视为慢速客户端断开连接
+// 与单次写入的超时时间相同：这段时间内一条数据都没有写出，说明写协程卡在了一次注定超时的写入上
+var sendGrace = writeWait
+
 type Client struct {
 	Addr          string
 	Socket        *websocket.Conn
-	send          chan []byte
+	send          chan []byte   // 有界发送缓冲区
+	drained       chan struct{} // 写协程每写出一条数据通知一次，等待缓冲区空间的发送方据此重新检查
+	done          chan struct{} // 连接关闭后关闭，通知写协程退出
+	closeOnce     sync.Once
 	AppID         uint32
 	UserID        uint
 	FirstTime     time.Time
 	HeartbeatTime time.Time
 	LoginTime     time.Time
+	Status        string       // 客户端上报的在线状态，online 或 away
+	lock          sync.RWMutex // 保护 HeartbeatTime 和 Status
 }
 
 func CreateClient(addr string, socket *websocket.Conn) *Client {
 	return &Client{
 		Addr:          addr,
 		Socket:        socket,
+		send:          make(chan []byte, sendBuffer()),
+		drained:       make(chan struct{}, 1),
+		done:          make(chan struct{}),
 		FirstTime:     time.Now(),
 		HeartbeatTime: time.Now(),
 		LoginTime:     time.Now(),
+		Status:        presence.StatusOnline,
 	}
 }
 
+// sendBuffer 每个连接的发送缓冲区大小
+func sendBuffer() int {
+	if size := viper.GetInt("websocket.sendBuffer"); size > 0 {
+		return size
+	}
+	return defaultSendBuffer
+}
+
+// Heartbeat 记录心跳时间和客户端上报的状态
+func (c *Client) Heartbeat(currentTime time.Time, status string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.HeartbeatTime = currentTime
+	c.Status = status
+}
+
+// IsHeartbeatTimeout 判断连接是否超过心跳超时时间没有心跳
+func (c *Client) IsHeartbeatTimeout(currentTime time.Time) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return currentTime.Sub(c.HeartbeatTime) > presence.Timeout()
+}
+
+// GetStatus 获取客户端上报的在线状态
+func (c *Client) GetStatus() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.Status
+}
+
 func (c *Client) Read() {
 	defer func() {
 		if err := recover(); err != nil {
@@ -35,8 +86,8 @@ func (c *Client) Read() {
 		}
 	}()
 	defer func() {
-		fmt.Println("读取客户数据 关闭send", c)
-		close(c.send)
+		fmt.Println("读取客户数据 关闭连接", c.Addr)
+		c.close()
 	}()
 	for {
 		_, message, err := c.Socket.ReadMessage()
@@ -45,6 +96,7 @@ func (c *Client) Read() {
 			return
 		}
 		fmt.Println("读取客户端数据：", string(message))
+		ProcessData(c, message)
 
 	}
 }
@@ -56,21 +108,112 @@ func (c *Client) Write() {
 		}
 	}()
 	defer func() {
-		clientManager.Disconnect <- c
+		clientManager.Events <- clientEvent{Type: eventDisconnect, Client: c}
 		_ = c.Socket.Close()
-		fmt.Println("Client发送数据 defer", c)
+		fmt.Println("Client发送数据 defer", c.Addr)
 	}()
 	for {
 		select {
-		case message, ok := <-c.send:
-			if !ok {
-				fmt.Println("Client发送数据 关闭连接", c.Addr, "ok", ok)
+		case message := <-c.send:
+			// 写入超时说明客户端长时间不读取数据，直接断开，未确认的消息留在离线队列中等待重新投递
+			_ = c.Socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Socket.WriteMessage(websocket.TextMessage, message); err != nil {
+				fmt.Println("Client发送数据 错误", c.Addr, err.Error())
 				return
 			}
-			err := c.Socket.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				panic(err)
+			select {
+			case c.drained <- struct{}{}:
+			default:
 			}
+		case <-c.done:
+			fmt.Println("Client发送数据 关闭连接", c.Addr)
+			return
+		}
+	}
+}
+
+// close 关闭连接并通知写协程退出，可以重复调用
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.Socket.Close()
+	})
+}
+
+// SendMsg 向客户端发送必须送达的数据，例如聊天消息和命令回复
+// 缓冲区已满时最多阻塞 sendGrace 等待写协程腾出空间，仍然没有空间说明客户端消费过慢，此时断开连接，
+// 消息仍保存在待确认队列中，客户端重连后重新投递
+func (c *Client) SendMsg(msg []byte) bool {
+	if c == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(sendGrace)
+	defer timer.Stop()
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.done:
+		return false
+	case <-timer.C:
+		c.evict()
+		return false
+	}
+}
+
+// sendPaced 等缓冲区使用量降到 limit 以下再发送，用于一次推送大量数据，为其他数据保留缓冲区空间
+// 写协程仍在写出数据时一直等待，超过 sendGrace 没有写出任何数据时断开连接
+func (c *Client) sendPaced(msg []byte, limit int) bool {
+	timer := time.NewTimer(sendGrace)
+	defer timer.Stop()
+	for len(c.send) >= limit {
+		select {
+		case <-c.drained:
+			timer.Reset(sendGrace)
+		case <-c.done:
+			return false
+		case <-timer.C:
+			c.evict()
+			return false
 		}
 	}
+	return c.SendMsg(msg)
+}
+
+// evict 断开消费过慢的客户端
+func (c *Client) evict() {
+	fmt.Println("发送缓冲区持续已满，断开慢速客户端", c.Addr, c.AppID, c.UserID)
+	c.close()
+}
+
+// TrySendMsg 发送可以丢弃的数据，例如输入状态和在线状态
+// 缓冲区使用超过四分之三时直接丢弃，为必须送达的数据保留空间
+func (c *Client) TrySendMsg(msg []byte) bool {
+	if c == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	if len(c.send) >= cap(c.send)*3/4 {
+		return false
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,54 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	pushCmd        = "push"          // 服务端主动推送帧的命令名
+	rerouteTimeout = 3 * time.Second // 转发信封到其他节点的超时时间
+)
+
+// encodePush 将信封编码为推送帧，推送帧与命令回复的格式相同，seq 为空
+func encodePush(env delivery.Envelope) ([]byte, error) {
+	return json.Marshal(Response{Cmd: pushCmd, Code: http.StatusOK, Msg: http.StatusText(http.StatusOK), Data: env})
+}
+
+// consumeStream 以消费者组读取本节点的消息流，同时认领失效节点留下的条目
+func consumeStream() {
+	node := NodeAddr()
+	fmt.Println("开始消费节点消息流", delivery.StreamName(node))
+	go delivery.Maintain(context.Background(), node, dispatchEnvelope)
+	delivery.Consume(context.Background(), node, dispatchEnvelope)
+}
+
+// dispatchEnvelope 将信封写入目标用户的连接，用户已经不在本节点时转发到其当前所在的节点
+func dispatchEnvelope(env delivery.Envelope) {
+	userID, err := strconv.Atoi(env.UserID)
+	if err != nil {
+		log.Printf("信封中的用户ID无效: %s", env.UserID)
+		return
+	}
+	if SendToUser(uint(userID), env) > 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rerouteTimeout)
+	defer cancel()
+	rerouted, err := delivery.Reroute(ctx, env, NodeAddr())
+	if err != nil {
+		log.Printf("转发用户 %s 的消息 %s 失败: %v", env.UserID, env.MessageID, err)
+		return
+	}
+	if !rerouted {
+		// 用户已离线，必须送达的消息仍在待确认队列中，上线后重新投递
+		log.Printf("用户 %s 不在线，丢弃节点流中的消息 %s", env.UserID, env.MessageID)
+	}
+}
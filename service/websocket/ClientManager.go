@@ -1,25 +1,390 @@
 package websocket
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/helpleness/IMChatAdmin/service/grpcclient"
+	"github.com/helpleness/IMChatAdmin/service/presence"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const presenceTimeout = 3 * time.Second // 读写在线状态的超时时间
+
+// clientEventType 连接生命周期事件的类型
+type clientEventType int
+
+const (
+	eventConnect    clientEventType = iota // 建立连接
+	eventLogin                             // 用户登录
+	eventDisconnect                        // 断开连接
+)
+
+// clientEvent 连接生命周期事件
+type clientEvent struct {
+	Type   clientEventType
+	Client *Client
+}
 
 type ClientManager struct {
 	Client      map[*Client]bool   //全部的连接
 	ClientsLock sync.RWMutex       //读写锁
 	Users       map[string]*Client //登陆的用户 //appID+uuid
 	UsersLock   sync.RWMutex       //读写锁
-	Connect     chan *Client       //连接处理
-	Disconnect  chan *Client       //断开连接处理
-	Login       chan *Client       //用户登录处理
-	Broadcast   chan *Client       //广播 向全部成员发送数据
+	Events      chan clientEvent   //连接、登录和断开事件，同一个通道保证按发生顺序处理
+	Broadcast   chan []byte        //广播 向全部成员发送数据
+	tasks       map[uint][]func()  //每个用户等待执行的 Redis/gRPC 操作，有记录说明该用户的工作协程正在运行
+	tasksLock   sync.Mutex
 }
 
 func NewClientManager() *ClientManager {
 	return &ClientManager{
-		Client:     make(map[*Client]bool),
-		Users:      make(map[string]*Client),
-		Connect:    make(chan *Client),
-		Disconnect: make(chan *Client),
-		Login:      make(chan *Client),
-		Broadcast:  make(chan *Client),
+		Client:    make(map[*Client]bool),
+		Users:     make(map[string]*Client),
+		Events:    make(chan clientEvent, 3000),
+		Broadcast: make(chan []byte, 1000),
+		tasks:     make(map[uint][]func()),
+	}
+}
+
+// GetUserKey 生成登录用户的键，同一用户在不同平台上各自保持一个连接
+func GetUserKey(appID uint32, userID uint) string {
+	return fmt.Sprintf("%d_%d", appID, userID)
+}
+
+// NodeAddr 当前节点的地址，登记到 user_nodes:<uid> 后其他节点据此把消息投递到本节点
+func NodeAddr() string {
+	return fmt.Sprintf("%s:%s", serverIp, serverPort)
+}
+
+// start 管理者事件循环，所有连接的注册、登录和断开都在这里按发生顺序串行处理
+func (manager *ClientManager) start() {
+	for event := range manager.Events {
+		switch event.Type {
+		case eventConnect:
+			manager.EventConnect(event.Client)
+		case eventLogin:
+			manager.EventLogin(event.Client)
+		case eventDisconnect:
+			manager.EventDisconnect(event.Client)
+		}
+	}
+}
+
+// broadcast 向全部连接发送广播数据
+// 缓冲区已满的连接会让 SendMsg 阻塞一段时间，因此不放在事件循环中处理，发送时也不持有连接表的锁
+func (manager *ClientManager) broadcast() {
+	for message := range manager.Broadcast {
+		manager.ClientsLock.RLock()
+		clients := make([]*Client, 0, len(manager.Client))
+		for client := range manager.Client {
+			clients = append(clients, client)
+		}
+		manager.ClientsLock.RUnlock()
+		for _, client := range clients {
+			client.SendMsg(message)
+		}
+	}
+}
+
+// EventConnect 记录新连接
+func (manager *ClientManager) EventConnect(client *Client) {
+	manager.AddClient(client)
+	fmt.Println("EventConnect 建立连接", client.Addr)
+}
+
+// EventLogin 用户登录：同一平台上已有的旧连接会被踢下线
+// 事件循环只修改连接表，踢掉其他节点上的连接、写入在线状态和重新投递交给该用户的工作协程执行
+func (manager *ClientManager) EventLogin(client *Client) {
+	if old := manager.AddUser(client); old != nil && old != client {
+		fmt.Println("EventLogin 同一平台重复登录，关闭旧连接", old.Addr)
+		old.close()
+	}
+	fmt.Println("EventLogin 用户登录", client.Addr, client.AppID, client.UserID)
+	manager.runForUser(client.UserID, func() { manager.onLogin(client) })
+}
+
+// onLogin 关闭用户在其他节点上同一平台的连接，写入在线状态、通知好友并重新投递待确认消息
+func (manager *ClientManager) onLogin(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	manager.kickRemoteLogin(ctx, client)
+	if err := setOnline(ctx, client.UserID); err != nil {
+		log.Printf("写入用户 %d 在线状态失败: %v", client.UserID, err)
+	}
+	if err := manager.updatePresence(ctx, client.UserID); err != nil {
+		log.Printf("更新用户 %d 在线状态失败: %v", client.UserID, err)
+	}
+
+	// 上线后重新投递该设备尚未确认的消息，推送速度取决于客户端，不占用用户的工作协程
+	go manager.redeliver(client)
+}
+
+// runForUser 将用户相关的 Redis/gRPC 操作交给该用户的工作协程，不会阻塞调用方
+// 同一用户的操作按提交顺序执行，登录和断开的处理顺序与事件循环一致；工作协程在没有待执行的操作时退出
+func (manager *ClientManager) runForUser(userID uint, task func()) {
+	manager.tasksLock.Lock()
+	defer manager.tasksLock.Unlock()
+	queue, running := manager.tasks[userID]
+	manager.tasks[userID] = append(queue, task)
+	if !running {
+		go manager.userWorker(userID)
+	}
+}
+
+// userWorker 依次执行用户等待执行的操作
+func (manager *ClientManager) userWorker(userID uint) {
+	for {
+		manager.tasksLock.Lock()
+		queue := manager.tasks[userID]
+		if len(queue) == 0 {
+			delete(manager.tasks, userID)
+			manager.tasksLock.Unlock()
+			return
+		}
+		task := queue[0]
+		manager.tasks[userID] = queue[1:]
+		manager.tasksLock.Unlock()
+		task()
+	}
+}
+
+// redeliver 登记用户的设备，并把该设备尚未确认的消息直接推送到这个连接
+// 待确认消息可能远多于发送缓冲区，按写协程的速度逐条推送，并为实时消息保留一半的缓冲区
+func (manager *ClientManager) redeliver(client *Client) {
+	ctx := context.Background()
+	userIDStr := strconv.Itoa(int(client.UserID))
+	if err := delivery.RegisterDevice(ctx, userIDStr, client.AppID); err != nil {
+		log.Printf("登记用户 %d 的设备 %d 失败: %v", client.UserID, client.AppID, err)
+	}
+	envelopes, err := delivery.Pending(ctx, userIDStr, client.AppID)
+	if err != nil {
+		log.Printf("重新投递用户 %d 消息失败: %v", client.UserID, err)
+		return
+	}
+	count := 0
+	limit := max(cap(client.send)/2, 1)
+	for _, env := range envelopes {
+		data, err := encodePush(env)
+		if err != nil {
+			log.Printf("编码推送帧失败: %v", err)
+			continue
+		}
+		if !client.sendPaced(data, limit) {
+			// 连接已关闭，剩余的消息下次登录时重新投递
+			break
+		}
+		count++
+	}
+	if count > 0 {
+		log.Printf("成功推送 %d 条待确认消息到用户 %d 的设备 %d", count, client.UserID, client.AppID)
+	}
+}
+
+// kickRemoteLogin 用户在其他节点上也有连接时，通过 gRPC 关闭那些节点上同一平台的连接
+func (manager *ClientManager) kickRemoteLogin(ctx context.Context, client *Client) {
+	nodes, err := delivery.UserNodes(ctx, database.GetRedisClient(), strconv.Itoa(int(client.UserID)))
+	if err != nil {
+		return
+	}
+	for _, node := range nodes {
+		if node == NodeAddr() {
+			continue
+		}
+		go func(node string) {
+			kicked, err := grpcclient.KickUser(context.Background(), node, client.UserID, client.AppID)
+			if err != nil {
+				log.Printf("关闭用户 %d 在节点 %s 上的连接失败: %v", client.UserID, node, err)
+				return
+			}
+			if kicked > 0 {
+				fmt.Println("EventLogin 关闭用户在其他节点上的同平台连接", node, client.AppID, client.UserID)
+			}
+		}(node)
+	}
+}
+
+// EventDisconnect 连接断开：移除连接，清除在线状态交给该用户的工作协程执行
+func (manager *ClientManager) EventDisconnect(client *Client) {
+	manager.DelClient(client)
+	if client.UserID == 0 {
+		return
+	}
+	if !manager.DelUser(client) {
+		// 该连接已经被同一平台的新连接替换
+		return
 	}
+	fmt.Println("EventDisconnect 用户断开连接", client.Addr, client.AppID, client.UserID)
+	manager.runForUser(client.UserID, func() { manager.onDisconnect(client.UserID) })
+}
+
+// onDisconnect 用户在本节点所有平台上都没有连接时，移除本节点的登记和本节点上报的在线状态
+// 用户在其他节点上仍有连接时，汇总状态由那些节点上报的状态决定
+func (manager *ClientManager) onDisconnect(userID uint) {
+	if len(manager.GetUserClients(userID)) > 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := setOffline(ctx, userID); err != nil {
+		log.Printf("清除用户 %d 的节点登记失败: %v", userID, err)
+	}
+	if err := manager.updatePresence(ctx, userID); err != nil {
+		log.Printf("更新用户 %d 在线状态失败: %v", userID, err)
+	}
+}
+
+// userStatus 汇总用户在本节点所有连接上报的状态，任一连接在线即为在线
+func (manager *ClientManager) userStatus(userID uint) string {
+	clients := manager.GetUserClients(userID)
+	if len(clients) == 0 {
+		return presence.StatusOffline
+	}
+	for _, client := range clients {
+		if client.GetStatus() == presence.StatusOnline {
+			return presence.StatusOnline
+		}
+	}
+	return presence.StatusAway
+}
+
+// updatePresence 写入用户在本节点上的状态，用户在所有节点上的汇总状态发生变化时异步通知在线的好友
+func (manager *ClientManager) updatePresence(ctx context.Context, userID uint) error {
+	status := manager.userStatus(userID)
+	var changed bool
+	var err error
+	if status == presence.StatusOffline {
+		changed, status, err = presence.SetOffline(ctx, userID, NodeAddr())
+	} else {
+		changed, status, err = presence.Set(ctx, userID, NodeAddr(), status)
+	}
+	if err != nil {
+		return err
+	}
+	if changed {
+		go presence.NotifyFriends(context.Background(), userID, status)
+	}
+	return nil
+}
+
+// clearTimeoutConnections 定期关闭超过心跳超时时间没有心跳的连接，连接关闭后按正常断开处理
+func (manager *ClientManager) clearTimeoutConnections() {
+	ticker := time.NewTicker(presence.Timeout() / 3)
+	defer ticker.Stop()
+	for currentTime := range ticker.C {
+		manager.ClientsLock.RLock()
+		for client := range manager.Client {
+			if client.IsHeartbeatTimeout(currentTime) {
+				fmt.Println("心跳超时 关闭连接", client.Addr, client.AppID, client.UserID)
+				client.close()
+			}
+		}
+		manager.ClientsLock.RUnlock()
+	}
+}
+
+// RefreshHeartbeat 处理客户端心跳：刷新心跳时间，并为用户的节点路由和在线状态续期
+func RefreshHeartbeat(ctx context.Context, client *Client, status string) error {
+	client.Heartbeat(time.Now(), status)
+	if client.UserID == 0 {
+		return nil
+	}
+	if err := setOnline(ctx, client.UserID); err != nil {
+		return err
+	}
+	return clientManager.updatePresence(ctx, client.UserID)
+}
+
+// AddClient 记录一个新的连接
+func (manager *ClientManager) AddClient(client *Client) {
+	manager.ClientsLock.Lock()
+	defer manager.ClientsLock.Unlock()
+	manager.Client[client] = true
+}
+
+// DelClient 移除连接
+func (manager *ClientManager) DelClient(client *Client) {
+	manager.ClientsLock.Lock()
+	defer manager.ClientsLock.Unlock()
+	delete(manager.Client, client)
+}
+
+// AddUser 记录已认证的用户连接，返回同一平台上被替换的旧连接
+func (manager *ClientManager) AddUser(client *Client) *Client {
+	manager.UsersLock.Lock()
+	defer manager.UsersLock.Unlock()
+	key := GetUserKey(client.AppID, client.UserID)
+	old := manager.Users[key]
+	manager.Users[key] = client
+	return old
+}
+
+// DelUser 移除用户连接，只有当前记录的就是该连接时才会删除
+func (manager *ClientManager) DelUser(client *Client) bool {
+	manager.UsersLock.Lock()
+	defer manager.UsersLock.Unlock()
+	key := GetUserKey(client.AppID, client.UserID)
+	if manager.Users[key] != client {
+		return false
+	}
+	delete(manager.Users, key)
+	return true
+}
+
+// GetUserClient 获取用户在指定平台上的连接
+func (manager *ClientManager) GetUserClient(appID uint32, userID uint) *Client {
+	manager.UsersLock.RLock()
+	defer manager.UsersLock.RUnlock()
+	return manager.Users[GetUserKey(appID, userID)]
+}
+
+// GetUserClients 获取用户在所有平台上的连接
+func (manager *ClientManager) GetUserClients(userID uint) []*Client {
+	manager.UsersLock.RLock()
+	defer manager.UsersLock.RUnlock()
+	var clients []*Client
+	for _, appID := range appIDs {
+		if client, ok := manager.Users[GetUserKey(appID, userID)]; ok {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// SendToUser 将信封推送到用户在本节点上的全部连接，返回用户在本节点上的连接数
+// 不在待确认队列中的事件在慢速客户端的缓冲区紧张时直接丢弃
+func SendToUser(userID uint, env delivery.Envelope) int {
+	clients := clientManager.GetUserClients(userID)
+	if len(clients) == 0 {
+		return 0
+	}
+	data, err := encodePush(env)
+	if err != nil {
+		log.Printf("编码推送帧失败: %v", err)
+		return len(clients)
+	}
+	for _, client := range clients {
+		if env.Transient {
+			client.TrySendMsg(data)
+		} else {
+			client.SendMsg(data)
+		}
+	}
+	return len(clients)
+}
+
+// setOnline 登记用户在本节点上有连接，delivery.UserNodes 据此确定投递目标
+// 登记在心跳超时后过期，节点崩溃时用户不会一直停留在该节点上；用户在其他节点上的登记互不影响
+func setOnline(ctx context.Context, userID uint) error {
+	return delivery.SetUserNode(ctx, strconv.Itoa(int(userID)), NodeAddr(), presence.Timeout())
+}
+
+// setOffline 移除用户在本节点上的登记
+func setOffline(ctx context.Context, userID uint) error {
+	_, err := delivery.ClearUserNode(ctx, strconv.Itoa(int(userID)), NodeAddr())
+	return err
 }
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunForUserOrdersTasksPerUser(t *testing.T) {
+	manager := NewClientManager()
+	var lock sync.Mutex
+	var order []int
+	record := func(n int) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, n)
+	}
+
+	blocked := make(chan struct{})
+	otherDone := make(chan struct{})
+	manager.runForUser(1, func() {
+		<-blocked
+		record(1)
+	})
+	manager.runForUser(1, func() { record(2) })
+	// 一个用户的慢操作不影响其他用户
+	manager.runForUser(2, func() {
+		record(100)
+		close(otherDone)
+	})
+	select {
+	case <-otherDone:
+	case <-time.After(time.Second):
+		t.Fatal("其他用户的操作被阻塞")
+	}
+
+	close(blocked)
+	deadline := time.Now().Add(time.Second)
+	for {
+		manager.tasksLock.Lock()
+		idle := len(manager.tasks) == 0
+		manager.tasksLock.Unlock()
+		if idle {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("工作协程没有在操作执行完后退出")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if want := []int{100, 1, 2}; !reflect.DeepEqual(order, want) {
+		t.Errorf("执行顺序 = %v, want %v", order, want)
+	}
+}
+
+func TestEventLoopDoesNotWaitForUserTasks(t *testing.T) {
+	manager := NewClientManager()
+	blocked := make(chan struct{})
+	defer close(blocked)
+	manager.runForUser(1, func() { <-blocked })
+
+	// 用户的工作协程阻塞时，同一用户的后续事件仍可立即提交
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			manager.runForUser(1, func() {})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runForUser 被用户的慢操作阻塞")
+	}
+}
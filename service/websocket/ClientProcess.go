@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// Request 客户端发来的命令帧
+type Request struct {
+	Seq  string          `json:"seq"`            // 客户端生成的请求序号，回复中原样带回
+	Cmd  string          `json:"cmd"`            // 命令名
+	Data json.RawMessage `json:"data,omitempty"` // 命令参数
+}
+
+// Response 服务端的回复帧，seq 和 cmd 与请求相同
+type Response struct {
+	Seq  string      `json:"seq"`
+	Cmd  string      `json:"cmd"`
+	Code uint32      `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// DisposeFunc 命令处理函数，返回的 code/msg/data 组成回复帧
+type DisposeFunc func(client *Client, seq string, message []byte) (code uint32, msg string, data interface{})
+
+var (
+	handlers        = make(map[string]DisposeFunc)
+	handlersRWMutex sync.RWMutex
+)
+
+// Register 按命令名注册处理函数
+func Register(key string, value DisposeFunc) {
+	handlersRWMutex.Lock()
+	defer handlersRWMutex.Unlock()
+	handlers[key] = value
+}
+
+// getHandlers 获取命令对应的处理函数
+func getHandlers(key string) (DisposeFunc, bool) {
+	handlersRWMutex.RLock()
+	defer handlersRWMutex.RUnlock()
+	value, ok := handlers[key]
+	return value, ok
+}
+
+// ProcessData 解析客户端发来的命令帧，调用对应的处理函数并回复
+func ProcessData(client *Client, message []byte) {
+	request := &Request{}
+	if err := json.Unmarshal(message, request); err != nil {
+		fmt.Println("处理数据 json Unmarshal", err)
+		client.SendResponse(Response{Code: http.StatusBadRequest, Msg: "数据不合法"})
+		return
+	}
+
+	response := Response{Seq: request.Seq, Cmd: request.Cmd}
+	handler, ok := getHandlers(request.Cmd)
+	if !ok {
+		response.Code = http.StatusNotFound
+		response.Msg = "未知的命令"
+		client.SendResponse(response)
+		return
+	}
+
+	response.Code, response.Msg, response.Data = callHandler(handler, client, request)
+	client.SendResponse(response)
+}
+
+// callHandler 调用处理函数，处理函数 panic 时回复服务端错误而不是断开连接
+func callHandler(handler DisposeFunc, client *Client, request *Request) (code uint32, msg string, data interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("处理命令 panic", request.Cmd, r, string(debug.Stack()))
+			code, msg, data = http.StatusInternalServerError, "服务端错误", nil
+		}
+	}()
+	return handler(client, request.Seq, request.Data)
+}
+
+// SendResponse 向客户端发送回复帧
+func (c *Client) SendResponse(response Response) {
+	if response.Msg == "" {
+		response.Msg = http.StatusText(int(response.Code))
+	}
+	responseMarshal, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("处理数据 json Marshal", err)
+		return
+	}
+	c.SendMsg(responseMarshal)
+}
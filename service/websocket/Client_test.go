@@ -0,0 +1,183 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+	"github.com/helpleness/IMChatAdmin/database"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// setupRedis 用 miniredis 替换全局 Redis 客户端
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = database.RedisClient.Close()
+		database.RedisClient = nil
+	})
+	return mr
+}
+
+// setSendBuffer 设置测试中新建连接的发送缓冲区大小
+func setSendBuffer(t *testing.T, size int) {
+	t.Helper()
+	viper.Set("websocket.sendBuffer", size)
+	t.Cleanup(func() { viper.Set("websocket.sendBuffer", 0) })
+}
+
+// setSendGrace 缩短缓冲区已满时的等待时间
+func setSendGrace(t *testing.T, grace time.Duration) {
+	t.Helper()
+	old := sendGrace
+	sendGrace = grace
+	t.Cleanup(func() { sendGrace = old })
+}
+
+// newTestClient 建立一对真实的 WebSocket 连接，返回服务端的 Client 和客户端一侧的连接
+func newTestClient(t *testing.T, userID uint, appID uint32) (*Client, *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade() err = %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() err = %v", err)
+	}
+	conn := <-accepted
+	client := CreateClient(conn.RemoteAddr().String(), conn)
+	client.UserID = userID
+	client.AppID = appID
+	t.Cleanup(func() {
+		client.close()
+		_ = peer.Close()
+	})
+	return client, peer
+}
+
+// isClosed 判断连接是否已被服务端关闭
+func isClosed(client *Client) bool {
+	select {
+	case <-client.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSendMsgWaitsForWriter(t *testing.T) {
+	setSendBuffer(t, 2)
+	setSendGrace(t, time.Second)
+	client, _ := newTestClient(t, 1, defaultAppID)
+	for i := 0; i < cap(client.send); i++ {
+		if !client.SendMsg([]byte("x")) {
+			t.Fatalf("SendMsg() 缓冲区未满时返回 false")
+		}
+	}
+
+	// 写协程在等待期间腾出空间，发送成功且不断开连接
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-client.send
+	}()
+	if !client.SendMsg([]byte("y")) {
+		t.Fatal("SendMsg() = false，want 等待写协程腾出空间后发送成功")
+	}
+	if isClosed(client) {
+		t.Error("缓冲区短暂已满时不应断开连接")
+	}
+}
+
+func TestSendMsgEvictsStalledClient(t *testing.T) {
+	setSendBuffer(t, 2)
+	setSendGrace(t, 50*time.Millisecond)
+	client, _ := newTestClient(t, 1, defaultAppID)
+	for i := 0; i < cap(client.send); i++ {
+		client.SendMsg([]byte("x"))
+	}
+
+	start := time.Now()
+	if client.SendMsg([]byte("y")) {
+		t.Fatal("SendMsg() = true，want 缓冲区持续已满时返回 false")
+	}
+	if elapsed := time.Since(start); elapsed < sendGrace {
+		t.Errorf("SendMsg() 在 %v 后就放弃，want 至少等待 %v", elapsed, sendGrace)
+	}
+	if !isClosed(client) {
+		t.Error("缓冲区持续已满时应当断开连接")
+	}
+	// 可以丢弃的数据不会等待
+	if client.TrySendMsg([]byte("z")) {
+		t.Error("TrySendMsg() 在连接关闭后返回 true")
+	}
+}
+
+func TestRedeliverMoreThanBuffer(t *testing.T) {
+	setupRedis(t)
+	setSendBuffer(t, 4)
+	setSendGrace(t, time.Second)
+	ctx := context.Background()
+
+	const total = 20
+	for i := 0; i < total; i++ {
+		env, err := delivery.NewEnvelope("1", "m"+strconv.Itoa(i), delivery.EventMessage, map[string]int{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := delivery.Enqueue(ctx, env); err != nil {
+			t.Fatalf("Enqueue() err = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	client, peer := newTestClient(t, 1, defaultAppID)
+	done := make(chan struct{})
+	go func() {
+		clientManager.redeliver(client)
+		close(done)
+	}()
+	// 写协程晚于重新投递启动，缓冲区在此期间保持已满
+	time.Sleep(50 * time.Millisecond)
+	go client.Write()
+
+	_ = peer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i := 0; i < total; i++ {
+		_, frame, err := peer.ReadMessage()
+		if err != nil {
+			t.Fatalf("读取第 %d 条推送失败: %v", i, err)
+		}
+		var push struct {
+			Cmd  string            `json:"cmd"`
+			Data delivery.Envelope `json:"data"`
+		}
+		if err := json.Unmarshal(frame, &push); err != nil {
+			t.Fatalf("解析推送帧失败: %v", err)
+		}
+		if want := "m" + strconv.Itoa(i); push.Cmd != pushCmd || push.Data.MessageID != want {
+			t.Fatalf("第 %d 条推送 = %s %s，want %s %s", i, push.Cmd, push.Data.MessageID, pushCmd, want)
+		}
+	}
+	<-done
+	if isClosed(client) {
+		t.Error("待确认消息多于发送缓冲区时不应断开连接")
+	}
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/helpleness/IMChatAdmin/service/grpcclient"
+	"github.com/helpleness/IMChatAdmin/service/protobuf"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"strconv"
+)
+
+// grpcServer 本节点的 gRPC 投递服务，其他节点和 service 层通过它直接推送消息
+type grpcServer struct {
+	protobuf.UnimplementedDeliveryServerServer
+}
+
+// newGrpcServer 在本节点地址的 websocket.rpcPort 上绑定 gRPC 投递服务，由调用方开始服务
+// 只监听登记给其他节点的地址，并要求调用方携带 websocket.rpcSecret 共享密钥；未配置密钥时返回 nil，不提供 gRPC 服务
+func newGrpcServer() (*grpc.Server, net.Listener, error) {
+	secret := grpcclient.Secret()
+	if secret == "" {
+		log.Printf("未配置 websocket.rpcSecret，不启动 gRPC 投递服务，节点之间只通过 Redis 消息流投递")
+		return nil, nil, nil
+	}
+	listener, err := net.Listen("tcp", NodeAddr())
+	if err != nil {
+		return nil, nil, err
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcclient.AuthInterceptor(secret)))
+	protobuf.RegisterDeliveryServerServer(server, &grpcServer{})
+	fmt.Println("gRPC 投递服务监听成功", NodeAddr())
+	return server, listener, nil
+}
+
+// decodeEnvelope 解析请求中的信封
+func decodeEnvelope(data []byte) (delivery.Envelope, error) {
+	var env delivery.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, status.Errorf(codes.InvalidArgument, "信封格式错误: %v", err)
+	}
+	return env, nil
+}
+
+// DeliverToUser 将信封推送到用户在本节点上的全部连接
+func (*grpcServer) DeliverToUser(ctx context.Context, req *protobuf.DeliverToUserReq) (*protobuf.DeliverToUserRsp, error) {
+	env, err := decodeEnvelope(req.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := strconv.Atoi(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "无效的用户ID: %s", req.UserId)
+	}
+	env.UserID = req.UserId
+	return &protobuf.DeliverToUserRsp{Delivered: SendToUser(uint(userID), env) > 0}, nil
+}
+
+// KickUser 关闭用户在本节点上的连接
+func (*grpcServer) KickUser(ctx context.Context, req *protobuf.KickUserReq) (*protobuf.KickUserRsp, error) {
+	var clients []*Client
+	if req.AppId == 0 {
+		clients = clientManager.GetUserClients(uint(req.UserId))
+	} else if client := clientManager.GetUserClient(req.AppId, uint(req.UserId)); client != nil {
+		clients = []*Client{client}
+	}
+	for _, client := range clients {
+		fmt.Println("KickUser 关闭连接", client.Addr, client.AppID, client.UserID)
+		client.close()
+	}
+	return &protobuf.KickUserRsp{Kicked: int32(len(clients))}, nil
+}
+
+// QueryOnline 查询一组用户中哪些在本节点上有连接
+func (*grpcServer) QueryOnline(ctx context.Context, req *protobuf.QueryOnlineReq) (*protobuf.QueryOnlineRsp, error) {
+	online := make([]uint64, 0, len(req.UserIds))
+	for _, userID := range req.UserIds {
+		if len(clientManager.GetUserClients(uint(userID))) > 0 {
+			online = append(online, userID)
+		}
+	}
+	return &protobuf.QueryOnlineRsp{OnlineUserIds: online}, nil
+}
+
+// BroadcastToGroup 将同一个信封推送给一组用户在本节点上的连接
+func (*grpcServer) BroadcastToGroup(ctx context.Context, req *protobuf.BroadcastToGroupReq) (*protobuf.BroadcastToGroupRsp, error) {
+	env, err := decodeEnvelope(req.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	delivered := make([]string, 0, len(req.UserIds))
+	for _, userIDStr := range req.UserIds {
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			continue
+		}
+		env.UserID = userIDStr
+		if SendToUser(uint(userID), env) > 0 {
+			delivered = append(delivered, userIDStr)
+		}
+	}
+	return &protobuf.BroadcastToGroupRsp{DeliveredUserIds: delivered}, nil
+}
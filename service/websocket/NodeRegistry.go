@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"github.com/helpleness/IMChatAdmin/service/delivery"
+	"github.com/helpleness/IMChatAdmin/service/registry"
+	"log"
+	"net"
+	"time"
+)
+
+// localIP 未配置 websocket.ip 时使用本机第一个非回环的 IPv4 地址
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Printf("获取本机地址失败: %v", err)
+		return "127.0.0.1"
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return "127.0.0.1"
+}
+
+// currentNode 本节点的注册信息
+func currentNode() registry.Node {
+	clientManager.ClientsLock.RLock()
+	connections := len(clientManager.Client)
+	clientManager.ClientsLock.RUnlock()
+	return registry.Node{
+		Addr:        NodeAddr(),
+		IP:          serverIp,
+		Port:        webSocketPort,
+		RPCPort:     serverPort,
+		Connections: connections,
+	}
+}
+
+// registerNode 定期把本节点的地址和连接数写入注册表，进程崩溃时注册信息会在过期后自动消失
+func registerNode() {
+	ticker := time.NewTicker(registry.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+		if err := registry.Register(ctx, currentNode()); err != nil {
+			log.Printf("注册节点 %s 失败: %v", NodeAddr(), err)
+		}
+		cancel()
+		<-ticker.C
+	}
+}
+
+// Shutdown 节点退出前从注册表中注销，并让其他节点立即接管本节点消息流中未确认的条目
+func Shutdown() {
+	if serverIp == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := registry.Unregister(ctx, NodeAddr()); err != nil {
+		log.Printf("注销节点 %s 失败: %v", NodeAddr(), err)
+	}
+	if err := delivery.Retire(ctx, NodeAddr()); err != nil {
+		log.Printf("清除节点 %s 存活标记失败: %v", NodeAddr(), err)
+	}
+	fmt.Println("WebSocket 节点已注销", NodeAddr())
+}
@@ -1,56 +1,167 @@
 package websocket
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gorilla/websocket"
+	"github.com/helpleness/IMChatAdmin/middleware"
+	"github.com/helpleness/IMChatAdmin/model"
 	"github.com/spf13/viper"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 const (
-	defaultAppID = 101 // 默认平台ID
+	defaultAppID   = 101      // 默认平台ID
+	bearerProtocol = "bearer" // 携带 token 的子协议名
 )
 
 var (
 	clientManager = NewClientManager()                    // 管理者
 	appIDs        = []uint32{defaultAppID, 102, 103, 104} // 全部的平台
-	serverIp      string
-	serverPort    string
+	serverIp      string                                  // 本节点地址，未配置时自动获取
+	serverPort    string                                  // gRPC 投递服务端口
+	webSocketPort string                                  // WebSocket 端口
 )
 
+// SocketStart 启动 WebSocket 服务和 gRPC 投递服务
+// 两个端口都绑定成功后才开始消费消息流并登记到节点注册表，任一服务失败时注销节点并退出进程
 func SocketStart() {
-	defer func() {
-		if err := recover(); err != nil {
-		}
-	}()
 	serverIp = viper.GetString("websocket.ip")
+	if serverIp == "" {
+		serverIp = localIP()
+	}
 	serverPort = viper.GetString("websocket.rpcPort")
-	WebSocketPort := viper.GetString("websocket.port")
-	http.HandleFunc("/ws/default.io", wsPage)
-	fmt.Println("WebSocket 启动程序成功", serverIp, serverPort)
-	err := http.ListenAndServe(":"+WebSocketPort, nil)
+	webSocketPort = viper.GetString("websocket.port")
+
+	wsListener, err := net.Listen("tcp", ":"+webSocketPort)
 	if err != nil {
-		panic(err)
+		log.Fatalf("WebSocket 服务监听端口 %s 失败: %v", webSocketPort, err)
 	}
+	rpcServer, rpcListener, err := newGrpcServer()
+	if err != nil {
+		_ = wsListener.Close()
+		log.Fatalf("gRPC 投递服务监听 %s 失败: %v", NodeAddr(), err)
+	}
+
+	go clientManager.start()
+	go clientManager.broadcast()
+	go clientManager.clearTimeoutConnections()
+	go consumeStream()
+	if rpcServer != nil {
+		go func() {
+			err := rpcServer.Serve(rpcListener)
+			Shutdown()
+			log.Fatalf("gRPC 投递服务退出: %v", err)
+		}()
+	}
+	go registerNode()
+
+	http.HandleFunc("/ws/default.io", wsPage)
+	fmt.Println("WebSocket 启动程序成功", serverIp, serverPort)
+	err = http.Serve(wsListener, nil)
+	Shutdown()
+	log.Fatalf("WebSocket 服务退出: %v", err)
 }
+
 func wsPage(w http.ResponseWriter, r *http.Request) {
+	// 先校验来源再认证，跨站页面无法借用户的 token 探测认证结果
+	if !checkOrigin(r) {
+		http.Error(w, "不允许的来源", http.StatusForbidden)
+		return
+	}
+	// 握手阶段完成认证，未通过认证的请求不会升级为 WebSocket 连接
+	user, err := authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	appID, err := ParseAppID(r.URL.Query().Get("appId"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// 将 HTTP 请求升级为 WebSocket 连接
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		fmt.Println("Failed to upgrade to websocket:", err)
 		return
 	}
-	fmt.Println("Client connected!", conn.RemoteAddr().String())
+	fmt.Println("Client connected!", conn.RemoteAddr().String(), "user", user.ID)
 	client := CreateClient(conn.RemoteAddr().String(), conn)
+	client.AppID = appID
+	client.UserID = user.ID
+	// 握手时已经完成认证，连接建立后直接登录
+	// 先排入连接和登录事件再开始读写，断开事件一定排在它们之后处理
+	clientManager.Events <- clientEvent{Type: eventConnect, Client: client}
+	clientManager.Events <- clientEvent{Type: eventLogin, Client: client}
 	go client.Read()
 	go client.Write()
-	clientManager.Connect <- client
+}
+
+// authenticate 从 Authorization 头、Sec-WebSocket-Protocol 子协议或 token 查询参数中取出 token 并校验
+// 浏览器无法为 WebSocket 设置请求头，可以使用子协议 "bearer, <token>" 或 ?token=<token>
+func authenticate(r *http.Request) (model.User, error) {
+	tokenString := ""
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		tokenString = header[7:]
+	} else if protocols := websocket.Subprotocols(r); len(protocols) >= 2 && protocols[0] == bearerProtocol {
+		tokenString = protocols[1]
+	} else {
+		tokenString = r.URL.Query().Get("token")
+	}
+	if tokenString == "" {
+		return model.User{}, errors.New("token验证失败")
+	}
+	return middleware.UserFromToken(r.Context(), tokenString)
+}
+
+// ParseAppID 解析平台ID，未指定时使用默认平台，HTTP 接口确认消息时也用它识别设备
+func ParseAppID(appIDStr string) (uint32, error) {
+	if appIDStr == "" {
+		return defaultAppID, nil
+	}
+	appID, err := strconv.ParseUint(appIDStr, 10, 32)
+	if err != nil {
+		return 0, errors.New("无效的平台ID")
+	}
+	for _, id := range appIDs {
+		if id == uint32(appID) {
+			return id, nil
+		}
+	}
+	return 0, errors.New("不支持的平台")
+}
+
+// checkOrigin 校验请求来源是否在 websocket.allowedOrigins 中
+// 没有 Origin 头的请求来自非浏览器客户端，直接放行；未配置允许列表时只允许同源
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	allowedOrigins := viper.GetStringSlice("websocket.allowedOrigins")
+	if len(allowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
 }
 
 // 定义 WebSocket 升级器
 var upgrader = websocket.Upgrader{
-	// 允许跨域请求
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	// 只允许配置中的来源跨域连接
+	CheckOrigin: checkOrigin,
+	// 通过子协议传递 token 时，服务端需要回应选中的子协议
+	Subprotocols: []string{bearerProtocol},
 }
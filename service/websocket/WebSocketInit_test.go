@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWsPageChecksOriginBeforeAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		// 没有 token 的请求，来源不合法时在认证之前就被拒绝
+		{"跨站来源", "http://evil.example", http.StatusForbidden},
+		{"同源", "http://im.example", http.StatusUnauthorized},
+		{"没有来源", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://im.example/acc", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			wsPage(w, r)
+			if w.Code != tt.want {
+				t.Errorf("状态码 = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -10,9 +10,8 @@ import (
 
 func InitPoll() {
 
-	// 创建一个 ants 池
+	// 创建一个 ants 池，定时任务在整个进程生命周期内都会向其提交任务，因此不在这里释放
 	p, _ := ants.NewPool(10, ants.WithExpiryDuration(5*time.Second))
-	defer p.Release()
 
 	// 创建一个定时任务调度器
 	c := cron.New(cron.WithSeconds())
@@ -30,6 +29,29 @@ func InitPoll() {
 		log.Fatalf("Error adding cron job: %v", err)
 	}
 
+	// 每10秒发送一次到期的定时消息
+	_, err = c.AddFunc("*/10 * * * * *", func() {
+		_ = p.Submit(func() {
+			controller.SendDueScheduledMessages()
+		})
+	})
+	if err != nil {
+		log.Fatalf("Error adding cron job: %v", err)
+	}
+
+	// 首次部署时在后台从已有消息重建附件引用计数，重建完成前清理任务不删除附件
+	go controller.BackfillAttachmentRefs()
+
+	// 每分钟清理一次过期的阅后即焚消息
+	_, err = c.AddFunc("0 * * * * *", func() {
+		_ = p.Submit(func() {
+			controller.PurgeExpiredMessages()
+		})
+	})
+	if err != nil {
+		log.Fatalf("Error adding cron job: %v", err)
+	}
+
 	// 启动定时任务
 	c.Start()
 }